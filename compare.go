@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/artifacts"
+	"github.com/howardjohn/prow-tracing/internal/span"
+	"github.com/howardjohn/prow-tracing/internal/stats"
+	"golang.org/x/exp/slog"
+)
+
+const compareUsage = `usage:
+  prow-tracing compare [--window DURATION] [--split TIME] [--threshold FRACTION] JOB`
+
+// compare contrasts span durations between runs of a job in two adjacent windows, exiting non-zero if
+// any span got significantly slower. By default, the last window is compared with the one before it.
+func compare(args []string) {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	window := flags.Duration("window", 7*24*time.Hour, "length of each window")
+	split := flags.String("split", "", "RFC 3339 time the windows meet at, such as when a commit merged; defaults to one window ago")
+	threshold := flags.Float64("threshold", 0.1, "smallest relative increase in median duration that counts as a regression")
+	alpha := flags.Float64("alpha", 0.05, "significance level for a slowdown to count as a regression")
+	minRuns := flags.Int("min-runs", 5, "fewest runs each window needs for a span to be tested")
+	opts := addBuildFlags(flags)
+	fatal(flags.Parse(args))
+	if flags.NArg() != 1 {
+		log.Fatal(compareUsage)
+	}
+	at := time.Now().Add(-*window)
+	if *split != "" {
+		t, err := time.Parse(time.RFC3339, *split)
+		fatal(err)
+		at = t
+	}
+
+	baseline, current, err := sampleWindows(jobHistory(flags.Arg(0)), at, *window, *opts)
+	fatal(err)
+	slog.Info("fetched runs", "baseline", len(baseline["job"]), "current", len(current["job"]))
+
+	res := stats.Compare(baseline, current, stats.CompareOptions{Alpha: *alpha, Threshold: *threshold, MinRuns: *minRuns})
+	fatal(writeComparison(os.Stdout, res))
+	for _, c := range res {
+		if c.Regressed {
+			os.Exit(1)
+		}
+	}
+}
+
+// sampleWindows returns the span durations of the runs of a job in history that started in the window
+// before at, and in the window after it.
+func sampleWindows(history artifacts.Source, at time.Time, window time.Duration, opts buildOptions) (baseline, current stats.Samples, err error) {
+	runs, err := fetchRuns(history, runOptions{Since: time.Since(at.Add(-window))})
+	if err != nil {
+		return nil, nil, err
+	}
+	baseline, current = stats.Samples{}, stats.Samples{}
+	for _, job := range runs {
+		started := fromEpoch(job.started.Timestamp)
+		var samples stats.Samples
+		switch {
+		case started.Before(at):
+			samples = baseline
+		case started.Before(at.Add(window)):
+			samples = current
+		default:
+			continue
+		}
+		samples.Add(span.Durations(buildJob(job, opts)))
+	}
+	return baseline, current, nil
+}
+
+func writeComparison(w io.Writer, res []stats.Comparison) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tBASELINE RUNS\tBASELINE P50\tCURRENT RUNS\tCURRENT P50\tCHANGE\tP-VALUE\t")
+	for _, c := range res {
+		mark := ""
+		if c.Regressed {
+			mark = "REGRESSED"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%+.1f%%\t%.3f\t%v\n",
+			c.Path, c.Baseline.Count, roundDuration(c.Baseline.P50), c.Current.Count, roundDuration(c.Current.P50), 100*c.Change, c.PValue, mark)
+	}
+	return tw.Flush()
+}
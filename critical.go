@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/howardjohn/prow-tracing/internal/render"
+	"github.com/howardjohn/prow-tracing/internal/span"
+	"github.com/howardjohn/prow-tracing/internal/tracing"
+)
+
+// criticalPath prints the critical path of a job: the chain of spans that determined how long it took.
+func criticalPath(args []string) {
+	flags := flag.NewFlagSet("critical-path", flag.ExitOnError)
+	export := flags.Bool("export", false, "also export the trace, with spans on the critical path tagged critical_path=true")
+	opts := addBuildFlags(flags)
+	fatal(flags.Parse(args))
+	job, err := fetchJob(openJob(flags.Arg(0)))
+	fatal(err)
+
+	opts.Links = *export
+	root := buildJob(job, *opts)
+	fatal(render.CriticalPath(os.Stdout, root))
+	if *export {
+		span.TagCriticalPath(root)
+		fatal(tracing.Export(job.prowjob, root))
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/span"
+)
+
+const diffUsage = `usage:
+  prow-tracing diff [--correct-skew] JOB_A JOB_B`
+
+// diff compares two runs of a job, showing where the time went differently.
+func diff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	opts := addBuildFlags(flags)
+	fatal(flags.Parse(args))
+	if flags.NArg() != 2 {
+		log.Fatal(diffUsage)
+	}
+	a, err := fetchJob(openJob(flags.Arg(0)))
+	fatal(err)
+	b, err := fetchJob(openJob(flags.Arg(1)))
+	fatal(err)
+	fatal(writeDiff(os.Stdout, span.Diff(buildJob(a, *opts), buildJob(b, *opts))))
+}
+
+func writeDiff(w io.Writer, diffs []span.Difference) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tA\tB\tDELTA\tNOTES")
+	for _, d := range diffs {
+		a, b := "-", "-"
+		if d.InA {
+			a = roundDuration(d.A).String()
+		}
+		if d.InB {
+			b = roundDuration(d.B).String()
+		}
+		notes := []string{}
+		switch {
+		case !d.InA:
+			notes = append(notes, "only in B")
+		case !d.InB:
+			notes = append(notes, "only in A")
+		}
+		for _, c := range d.Attributes {
+			notes = append(notes, fmt.Sprintf("%v: %v -> %v", c.Key, orNone(c.A), orNone(c.B)))
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", d.Path, a, b, signedDuration(d.Delta()), strings.Join(notes, "; "))
+	}
+	return tw.Flush()
+}
+
+func signedDuration(d time.Duration) string {
+	if d > 0 {
+		return "+" + roundDuration(d).String()
+	}
+	return roundDuration(d).String()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const traceUsage = `usage:
+  prow-tracing trace exec [--name NAME] -- COMMAND [ARGS...]`
+
+func traceCmd(args []string) {
+	if len(args) == 0 || args[0] != "exec" {
+		log.Fatal(traceUsage)
+	}
+	traceExec(args[1:])
+}
+
+// traceExec runs a command, recording it as a span under the prow job trace. The trace context is
+// passed to the command via TRACEPARENT, so nested invocations are recorded as children.
+func traceExec(args []string) {
+	flagArgs, command, ok := cutArgs(args, "--")
+	if !ok || len(command) == 0 {
+		log.Fatal(traceUsage)
+	}
+	fs := flag.NewFlagSet("trace exec", flag.ExitOnError)
+	name := fs.String("name", "", "name of the span; defaults to the command")
+	fatal(fs.Parse(flagArgs))
+	if *name == "" {
+		*name = strings.Join(command, " ")
+	}
+
+	root, shutdown, err := tracing.NewAction(tracing.EnvIdentity())
+	fatal(err)
+	rec := root.Recording(*name, time.Now(), time.Time{})
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), rec.Environ()...)
+	// Prow sends SIGTERM when the job times out or is aborted. Pass it on rather than dying, so the
+	// command's span is still ended and flushed once it exits.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	err = cmd.Start()
+	if err == nil {
+		go func() {
+			for sig := range signals {
+				_ = cmd.Process.Signal(sig)
+			}
+		}()
+		err = cmd.Wait()
+	}
+	signal.Stop(signals)
+	end := time.Now()
+
+	attrs := []attribute.KeyValue{attribute.StringSlice("process.command_args", command)}
+	code := 0
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		log.Printf("failed to run %v: %v", command[0], err)
+		attrs = append(attrs, attribute.String("error", err.Error()))
+		code = 127
+	}
+	if cmd.ProcessState != nil {
+		var stateAttrs []attribute.KeyValue
+		code, stateAttrs = processAttributes(cmd.ProcessState)
+		attrs = append(attrs, stateAttrs...)
+	}
+	rec.SetAttributes(attrs...)
+	rec.EndAt(end)
+	shutdown()
+	os.Exit(code)
+}
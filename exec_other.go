@@ -0,0 +1,20 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// processAttributes returns the exit code of a completed process, along with attributes describing
+// how it exited and the resources it used.
+func processAttributes(state *os.ProcessState) (int, []attribute.KeyValue) {
+	code := state.ExitCode()
+	return code, []attribute.KeyValue{
+		attribute.Float64("process.cpu.user", state.UserTime().Seconds()),
+		attribute.Float64("process.cpu.system", state.SystemTime().Seconds()),
+		attribute.Int("process.exit_code", code),
+	}
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// processAttributes returns the exit code of a completed process, along with attributes describing
+// how it exited and the resources it used.
+func processAttributes(state *os.ProcessState) (int, []attribute.KeyValue) {
+	code := state.ExitCode()
+	attrs := []attribute.KeyValue{
+		attribute.Float64("process.cpu.user", state.UserTime().Seconds()),
+		attribute.Float64("process.cpu.system", state.SystemTime().Seconds()),
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		attrs = append(attrs, attribute.String("process.signal", ws.Signal().String()))
+		// Match the shell convention for processes killed by a signal.
+		code = 128 + int(ws.Signal())
+	}
+	attrs = append(attrs, attribute.Int("process.exit_code", code))
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		maxRSS := ru.Maxrss
+		if runtime.GOOS != "darwin" {
+			// Reported in kilobytes everywhere except macOS.
+			maxRSS *= 1024
+		}
+		attrs = append(attrs, attribute.Int64("process.max_rss", maxRSS))
+	}
+	return code, attrs
+}
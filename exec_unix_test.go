@@ -0,0 +1,75 @@
+//go:build unix
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/howardjohn/prow-tracing/internal/tracing"
+)
+
+// TestTraceExecSignal checks that when trace exec is terminated, as Prow does when a job times out, it
+// passes the signal on to the command and still exports its span.
+func TestTraceExecSignal(t *testing.T) {
+	if args := os.Getenv("PROW_TRACING_TEST_EXEC_ARGS"); args != "" {
+		// Running as the wrapper, in a subprocess started below.
+		traceExec(strings.Split(args, "\n"))
+		return
+	}
+
+	file := filepath.Join(t.TempDir(), "traces.jsonl")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestTraceExecSignal$")
+	cmd.Env = append(os.Environ(),
+		"PROW_TRACING_TEST_EXEC_ARGS="+strings.Join([]string{"--name", "sleep", "--", "sh", "-c", "echo started; exec sleep 60"}, "\n"),
+		"OTEL_TRACES_EXPORTER=file",
+		"PROW_TRACING_FILE="+file,
+		"PROW_JOB_ID=9a3b7c1e-4f2d-11ee-8c99-0242ac120002",
+		"ARTIFACTS=",
+		"TRACEPARENT=",
+	)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the command to start before terminating the wrapper.
+	if line, err := bufio.NewReader(stdout).ReadString('\n'); err != nil || line != "started\n" {
+		t.Fatalf("command did not start: %q, %v", line, err)
+	}
+	if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+	var exitErr *exec.ExitError
+	if err := cmd.Wait(); !errors.As(err, &exitErr) || exitErr.ExitCode() != 128+int(syscall.SIGTERM) {
+		t.Fatalf("wrapper exited with %v, want the status of a command killed by SIGTERM", err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	spans, err := tracing.ReadTraceFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 1 || spans[0].Name() != "sleep" {
+		t.Fatalf("got %d spans, want the span of the command", len(spans))
+	}
+	attrs := map[string]string{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if got := attrs["process.signal"]; got != syscall.SIGTERM.String() {
+		t.Fatalf("got process.signal %q, want %q", got, syscall.SIGTERM.String())
+	}
+}
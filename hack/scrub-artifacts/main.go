@@ -0,0 +1,245 @@
+// Command scrub-artifacts copies the artifacts of a job run that traces are built from, anonymizing
+// them so they can be checked in as test fixtures.
+//
+//	gsutil -m cp -r gs://istio-prow/pr-logs/pull/istio_istio/12345/unit-tests_istio/1670000000000000000 /tmp/run
+//	go run ./hack/scrub-artifacts /tmp/run testdata/jobs/NAME
+//
+// PR authors, node names, IP addresses, email addresses and the values of environment variables are
+// replaced with placeholders, consistently across all files, so the fixture still hangs together.
+// Everything else, including timestamps, SHAs and clone records, is kept as-is.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// files are the artifacts traces are built from. Logs and other artifacts are not copied, as they are
+// large and hard to anonymize.
+var files = []string{
+	"prowjob.json",
+	"started.json",
+	"finished.json",
+	"podinfo.json",
+	"clone-records.json",
+	"artifacts/steps.jsonl",
+	"artifacts/spans.jsonl",
+}
+
+var (
+	emailRegexp = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	ipRegexp    = regexp.MustCompile(`\b\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\b`)
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		log.Fatal("usage: scrub-artifacts SRC DST")
+	}
+	if err := scrub(os.Args[1], os.Args[2]); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// scrub copies the artifacts in src to dst, anonymizing them.
+func scrub(src, dst string) error {
+	docs := map[string][]any{}
+	for _, f := range files {
+		b, err := os.ReadFile(filepath.Join(src, f))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		d, err := decode(f, b)
+		if err != nil {
+			return fmt.Errorf("%v: %v", f, err)
+		}
+		docs[f] = d
+	}
+
+	s := newScrubber()
+	// Collect in a fixed order, so placeholders are numbered the same each time.
+	for _, f := range files {
+		for _, v := range docs[f] {
+			s.collect(v, "")
+		}
+	}
+	for f, d := range docs {
+		for _, v := range d {
+			redactEnv(v)
+		}
+		b, err := encode(f, d)
+		if err != nil {
+			return fmt.Errorf("%v: %v", f, err)
+		}
+		out := filepath.Join(dst, f)
+		if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(out, s.replace(b), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decode parses a JSON file, or a file of JSON lines.
+func decode(name string, b []byte) ([]any, error) {
+	var res []any
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	for dec.More() {
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+		if !strings.HasSuffix(name, ".jsonl") {
+			break
+		}
+	}
+	return res, nil
+}
+
+func encode(name string, docs []any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if !strings.HasSuffix(name, ".jsonl") {
+		enc.SetIndent("", "  ")
+	}
+	for _, d := range docs {
+		if err := enc.Encode(d); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// scrubber replaces identifying strings with placeholders.
+type scrubber struct {
+	// placeholders maps each identifying string to its replacement.
+	placeholders map[string]string
+	counts       map[string]int
+}
+
+func newScrubber() *scrubber {
+	return &scrubber{placeholders: map[string]string{}, counts: map[string]int{}}
+}
+
+// add records an identifying string, to be replaced by a placeholder of the given kind.
+func (s *scrubber) add(value, kind string) {
+	if value == "" {
+		return
+	}
+	if _, f := s.placeholders[value]; f {
+		return
+	}
+	s.counts[kind]++
+	n := s.counts[kind]
+	switch kind {
+	case "ip":
+		s.placeholders[value] = fmt.Sprintf("10.0.%d.%d", n/256, n%256)
+	case "email":
+		s.placeholders[value] = fmt.Sprintf("user-%d@example.com", n)
+	default:
+		s.placeholders[value] = fmt.Sprintf("%s-%d", kind, n)
+	}
+}
+
+// identifying are the keys whose values identify a person or machine, and the kind of placeholder
+// that replaces them.
+var identifying = map[string]string{
+	"author":            "author",
+	"node":              "node",
+	"nodeName":          "node",
+	"host":              "node",
+	"reportingInstance": "node",
+	"hostname":          "node",
+}
+
+// collect finds identifying strings in v, which is the value of key.
+func (s *scrubber) collect(v any, key string) {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s.collect(v[k], k)
+		}
+	case []any:
+		for _, e := range v {
+			s.collect(e, key)
+		}
+	case string:
+		if kind, f := identifying[key]; f {
+			s.add(v, kind)
+		}
+		for _, m := range emailRegexp.FindAllString(v, -1) {
+			s.add(m, "email")
+		}
+		for _, m := range ipRegexp.FindAllString(v, -1) {
+			s.add(m, "ip")
+		}
+	}
+}
+
+// redactEnv replaces the values of environment variables in v, as they may hold anything.
+func redactEnv(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if env, ok := e.([]any); ok && k == "env" {
+				for _, ev := range env {
+					if m, ok := ev.(map[string]any); ok {
+						if _, f := m["value"]; f {
+							m["value"] = "REDACTED"
+						}
+					}
+				}
+				continue
+			}
+			redactEnv(e)
+		}
+	case []any:
+		for _, e := range v {
+			redactEnv(e)
+		}
+	}
+}
+
+// replace replaces all identifying strings in b, as whole words so that, for instance, a short author
+// name is not replaced inside other words. Longer strings are preferred, so a string containing another
+// is replaced as a whole.
+func (s *scrubber) replace(b []byte) []byte {
+	if len(s.placeholders) == 0 {
+		return b
+	}
+	values := make([]string, 0, len(s.placeholders))
+	for v := range s.placeholders {
+		values = append(values, regexp.QuoteMeta(v))
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	re := regexp.MustCompile(`\b(?:` + strings.Join(values, "|") + `)\b`)
+	return re.ReplaceAllFunc(b, func(m []byte) []byte {
+		return []byte(s.placeholders[string(m)])
+	})
+}
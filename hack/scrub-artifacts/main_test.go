@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScrub(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(src, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("prowjob.json", `{
+  "spec": {
+    "refs": {"pulls": [{"author": "ann", "sha": "08e578723410a34053e31a90efc64566c608ae86"}]},
+    "pod_spec": {"containers": [{"name": "test", "env": [{"name": "TOKEN", "value": "hunter2"}, {"name": "FROM", "valueFrom": {}}]}]}
+  },
+  "status": {"startTime": "2023-06-20T17:03:41Z", "description": "Job <b>succeeded</b> for ann on planner"}
+}`)
+	write("started.json", `{"node": "gke-prow-pool-1-abcd", "timestamp": 1687280657}`)
+	write("podinfo.json", `{
+  "pod": {"spec": {"nodeName": "gke-prow-pool-1-abcd"}, "status": {"podIP": "10.52.3.17", "hostIP": "10.128.0.9"}},
+  "events": [{"message": "Pulled by ann.smith@example.org on gke-prow-pool-1-abcd", "reportingInstance": "gke-prow-pool-1-abcd"}]
+}`)
+	write("artifacts/steps.jsonl", `{"name":"build","phase":"start","time":"2023-06-20T17:04:20Z","attributes":{"host":"gke-prow-pool-1-abcd"}}
+{"name":"build","phase":"end","time":"2023-06-20T17:08:20Z"}
+`)
+	if err := scrub(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	prowjob, started, podinfo, steps := read("prowjob.json"), read("started.json"), read("podinfo.json"), read("artifacts/steps.jsonl")
+	all := prowjob + started + podinfo + steps
+	for _, leaked := range []string{"ann", "gke-prow-pool-1-abcd", "10.52.3.17", "10.128.0.9", "ann.smith@example.org", "hunter2"} {
+		for _, w := range strings.FieldsFunc(all, func(r rune) bool { return strings.ContainsRune(` "{}[]:,<>/`, r) }) {
+			if w == leaked {
+				t.Errorf("%q was not scrubbed", leaked)
+				break
+			}
+		}
+	}
+	for _, want := range []string{
+		// Placeholders are consistent across files.
+		`"author": "author-1"`,
+		`for author-1 on planner`,
+		`"nodeName": "node-1"`,
+		`"reportingInstance": "node-1"`,
+		`Pulled by user-1@example.com on node-1`,
+		`"value": "REDACTED"`,
+		`"valueFrom": {}`,
+		// Everything else, and HTML in strings, is kept as-is.
+		`"sha": "08e578723410a34053e31a90efc64566c608ae86"`,
+		`Job <b>succeeded</b>`,
+	} {
+		if !strings.Contains(all, want) {
+			t.Errorf("want %s in the output", want)
+		}
+	}
+	if !strings.Contains(started, `"node": "node-1"`) || !strings.Contains(started, `"timestamp": 1687280657`) {
+		t.Errorf("got started.json %s, want the node scrubbed and timestamp kept", started)
+	}
+	if lines := strings.Split(strings.TrimSpace(steps), "\n"); len(lines) != 2 || !strings.Contains(lines[0], `"host":"node-1"`) {
+		t.Errorf("got steps %s, want two JSON lines with the host scrubbed", steps)
+	}
+	if strings.Contains(podinfo, "10.52.3.17") || !strings.Contains(podinfo, `"podIP": "10.0.`) {
+		t.Errorf("got podinfo %s, want IP addresses replaced", podinfo)
+	}
+}
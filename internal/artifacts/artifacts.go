@@ -0,0 +1,60 @@
+// Package artifacts provides access to the artifacts of a job run, wherever they are stored.
+package artifacts
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Source is a directory of job artifacts.
+type Source interface {
+	// Open returns a reader for the artifact at path. Missing artifacts return an error wrapping
+	// fs.ErrNotExist.
+	Open(path string) (io.ReadCloser, error)
+	// List returns the names of the artifacts and directories immediately under the Source.
+	List() ([]string, error)
+	// Sub returns a Source for a path relative to this one. ".." may be used to access parent
+	// directories.
+	Sub(path string) Source
+}
+
+// Fetch reads the JSON artifact at path.
+func Fetch[T any](s Source, path string) (T, error) {
+	var res T
+	reader, err := s.Open(path)
+	if err != nil {
+		return res, err
+	}
+	defer reader.Close()
+	if err := json.NewDecoder(reader).Decode(&res); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// Dir is a Source reading artifacts from a local directory, laid out as they are in GCS.
+type Dir string
+
+var _ Source = Dir("")
+
+func (d Dir) Open(path string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), path))
+}
+
+func (d Dir) List() ([]string, error) {
+	entries, err := os.ReadDir(string(d))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, e.Name())
+	}
+	return res, nil
+}
+
+func (d Dir) Sub(path string) Source {
+	return Dir(filepath.Join(string(d), path))
+}
@@ -3,16 +3,26 @@ package gcs
 import (
 	"cloud.google.com/go/storage"
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
 	"path/filepath"
 	"strings"
+
+	"github.com/howardjohn/prow-tracing/internal/artifacts"
+	"google.golang.org/api/iterator"
 )
 
+// Client is an artifacts.Source reading from a GCS bucket.
 type Client struct {
 	bucket *storage.BucketHandle
 	base   string
 }
 
+var _ artifacts.Source = &Client{}
+
 func NewClient(base string) *Client {
 	c, err := storage.NewClient(context.Background())
 	if err != nil {
@@ -26,14 +36,41 @@ func NewClient(base string) *Client {
 	}
 }
 
-func Fetch[T any](c *Client, path string) (T, error) {
-	var res T
+// Open returns a reader for the object at path. Missing objects return an error wrapping fs.ErrNotExist.
+func (c *Client) Open(path string) (io.ReadCloser, error) {
 	reader, err := c.bucket.Object(filepath.Join(c.base, path)).NewReader(context.Background())
-	if err != nil {
-		return res, err
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, fmt.Errorf("%v: %w", path, fs.ErrNotExist)
 	}
-	if err := json.NewDecoder(reader).Decode(&res); err != nil {
-		return res, err
+	return reader, err
+}
+
+// Sub returns a Client for a path relative to this one. ".." may be used to access parent directories.
+func (c *Client) Sub(p string) artifacts.Source {
+	return &Client{
+		bucket: c.bucket,
+		base:   path.Join(c.base, p),
+	}
+}
+
+// List returns the names of the objects and directories immediately under the Client's path.
+func (c *Client) List() ([]string, error) {
+	prefix := strings.TrimSuffix(c.base, "/") + "/"
+	it := c.bucket.Objects(context.Background(), &storage.Query{Prefix: prefix, Delimiter: "/"})
+	res := []string{}
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := attrs.Name
+		if name == "" {
+			// Directories are returned as prefixes rather than objects.
+			name = attrs.Prefix
+		}
+		res = append(res, strings.TrimSuffix(strings.TrimPrefix(name, prefix), "/"))
 	}
-	return res, nil
 }
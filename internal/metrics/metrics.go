@@ -0,0 +1,163 @@
+// Package metrics records the durations of job phases as OpenTelemetry metrics, for dashboards and
+// alerts that span many jobs.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/aggregation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// DefaultBuckets are the histogram bucket boundaries, in seconds, used unless others are configured.
+// They cover everything from a quick schedule to a multi-hour job.
+var DefaultBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200, 14400}
+
+// Job is a finished job run, as recorded in metrics. Durations that are unknown are zero, and are not
+// recorded.
+type Job struct {
+	// Attributes identify the job, such as its name and result.
+	Attributes []attribute.KeyValue
+	// Total is how long the job took, from being triggered to completing.
+	Total time.Duration
+	// Queue is how long the job waited to be scheduled by Prow.
+	Queue time.Duration
+	// Schedule is how long the job's pod waited to be scheduled to a node.
+	Schedule time.Duration
+	// Clone is how long it took to clone the job's repositories.
+	Clone time.Duration
+	// Containers is how long each container of the job's pod ran for.
+	Containers map[string]time.Duration
+}
+
+// Recorder records jobs to the meter provider it was created with.
+type Recorder struct {
+	runs                                     metric.Int64Counter
+	total, queue, schedule, clone, container metric.Float64Histogram
+}
+
+// NewRecorder returns a Recorder creating its instruments with mp.
+func NewRecorder(mp metric.MeterProvider) (*Recorder, error) {
+	meter := mp.Meter("prow-tracing")
+	runs, err := meter.Int64Counter("prow.job.runs", metric.WithDescription("Job runs, by result."))
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{runs: runs}
+	for _, h := range []struct {
+		dest        *metric.Float64Histogram
+		name, descr string
+	}{
+		{&r.total, "prow.job.duration", "Time from a job being triggered to completing."},
+		{&r.queue, "prow.job.queue.duration", "Time a job waited to be scheduled by Prow."},
+		{&r.schedule, "prow.job.schedule.duration", "Time a job's pod waited to be scheduled to a node."},
+		{&r.clone, "prow.job.clone.duration", "Time taken to clone a job's repositories."},
+		{&r.container, "prow.job.container.duration", "Time each container of a job's pod ran for."},
+	} {
+		*h.dest, err = meter.Float64Histogram(h.name, metric.WithUnit("s"), metric.WithDescription(h.descr))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Record records the durations of a job.
+func (r *Recorder) Record(ctx context.Context, j Job) {
+	record := func(h metric.Float64Histogram, d time.Duration, attrs ...attribute.KeyValue) {
+		if d > 0 {
+			h.Record(ctx, d.Seconds(), metric.WithAttributes(append(attrs, j.Attributes...)...))
+		}
+	}
+	r.runs.Add(ctx, 1, metric.WithAttributes(j.Attributes...))
+	record(r.total, j.Total)
+	record(r.queue, j.Queue)
+	record(r.schedule, j.Schedule)
+	record(r.clone, j.Clone)
+	for name, d := range j.Containers {
+		record(r.container, d, semconv.K8SContainerName(name))
+	}
+}
+
+// NewMeterProvider returns a MeterProvider exporting to reader, with histograms using buckets.
+func NewMeterProvider(reader sdkmetric.Reader, buckets []float64) *sdkmetric.MeterProvider {
+	return sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("prowjob"))),
+		sdkmetric.WithView(sdkmetric.NewView(
+			sdkmetric.Instrument{Kind: sdkmetric.InstrumentKindHistogram},
+			sdkmetric.Stream{Aggregation: aggregation.ExplicitBucketHistogram{Boundaries: buckets}},
+		)),
+	)
+}
+
+// Exporter returns the metric exporter selected by OTEL_METRICS_EXPORTER: "otlp" or "none". If that is
+// unset, metrics follow traces: they are sent over OTLP if traces are, and are otherwise disabled, as a
+// file, console, Zipkin or Jaeger trace exporter implies there is no OTLP backend to receive them. It
+// returns nil if metrics are disabled.
+func Exporter() (sdkmetric.Exporter, error) {
+	name := os.Getenv("OTEL_METRICS_EXPORTER")
+	if name == "" {
+		switch os.Getenv("OTEL_TRACES_EXPORTER") {
+		case "", "otlp":
+			name = "otlp"
+		default:
+			name = "none"
+		}
+	}
+	switch name {
+	case "otlp":
+		return otlpExporter()
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported metrics exporter %v", name)
+	}
+}
+
+func otlpExporter() (sdkmetric.Exporter, error) {
+	proto := os.Getenv("OTEL_EXPORTER_OTLP_METRICS_PROTOCOL")
+	if proto == "" {
+		proto = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	if proto == "" {
+		proto = "grpc"
+	}
+	switch proto {
+	case "grpc":
+		log.Printf("using gRPC for metrics")
+		return otlpmetricgrpc.New(context.Background())
+	case "http/protobuf", "http/json":
+		// There is no JSON metric exporter, but OTLP/HTTP receivers accept protobuf as well as JSON.
+		log.Printf("using HTTP for metrics")
+		return otlpmetrichttp.New(context.Background())
+	default:
+		return nil, fmt.Errorf("unsupported otlp protocol for metrics %v", proto)
+	}
+}
+
+// Export records a single job to the exporter configured from the environment, if any.
+func Export(j Job) error {
+	exp, err := Exporter()
+	if err != nil || exp == nil {
+		return err
+	}
+	mp := NewMeterProvider(sdkmetric.NewPeriodicReader(exp), DefaultBuckets)
+	r, err := NewRecorder(mp)
+	if err != nil {
+		return err
+	}
+	r.Record(context.Background(), j)
+	// Shutting down collects and exports everything recorded.
+	return mp.Shutdown(context.Background())
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRecord(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	r, err := NewRecorder(NewMeterProvider(reader, []float64{10, 100}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job := attribute.String("prow.job", "unit-tests")
+	r.Record(context.Background(), Job{
+		Attributes: []attribute.KeyValue{job},
+		Total:      200 * time.Second,
+		Schedule:   2 * time.Second,
+		Containers: map[string]time.Duration{"test": 150 * time.Second, "sidecar": 160 * time.Second},
+	})
+
+	rm := metricdata.ResourceMetrics{}
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]metricdata.HistogramDataPoint[float64]{}
+	var runs []metricdata.DataPoint[int64]
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Histogram[float64]:
+				got[m.Name] = data.DataPoints
+			case metricdata.Sum[int64]:
+				runs = data.DataPoints
+			}
+		}
+	}
+	if len(runs) != 1 || runs[0].Value != 1 {
+		t.Errorf("want one run counted, got %+v", runs)
+	}
+	if _, f := got["prow.job.queue.duration"]; f {
+		t.Errorf("unknown durations should not be recorded")
+	}
+	total := got["prow.job.duration"]
+	if len(total) != 1 || total[0].Sum != 200 || total[0].BucketCounts[2] != 1 {
+		t.Errorf("unexpected job duration %+v", total)
+	}
+	if v, _ := total[0].Attributes.Value("prow.job"); v.AsString() != "unit-tests" {
+		t.Errorf("missing job attribute, got %v", total[0].Attributes)
+	}
+	if containers := got["prow.job.container.duration"]; len(containers) != 2 {
+		t.Errorf("want a data point per container, got %+v", containers)
+	}
+}
+
+func TestExporter(t *testing.T) {
+	cases := []struct {
+		name                   string
+		metrics, traces, proto string
+		enabled                bool
+	}{
+		{name: "default", enabled: true},
+		{name: "otlp traces", traces: "otlp", enabled: true},
+		{name: "http/json", proto: "http/json", enabled: true},
+		{name: "file traces", traces: "file"},
+		{name: "console traces", traces: "console"},
+		{name: "zipkin traces", traces: "zipkin"},
+		{name: "jaeger traces", traces: "jaeger"},
+		{name: "no traces", traces: "none"},
+		{name: "explicitly enabled", metrics: "otlp", traces: "file", enabled: true},
+		{name: "explicitly disabled", metrics: "none", traces: "otlp"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OTEL_METRICS_EXPORTER", tt.metrics)
+			t.Setenv("OTEL_TRACES_EXPORTER", tt.traces)
+			t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", tt.proto)
+			t.Setenv("OTEL_EXPORTER_OTLP_METRICS_PROTOCOL", "")
+			exp, err := Exporter()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := exp != nil; got != tt.enabled {
+				t.Fatalf("got enabled=%v, want %v", got, tt.enabled)
+			}
+			if exp != nil {
+				_ = exp.Shutdown(context.Background())
+			}
+		})
+	}
+}
+
+// TestExportDisabled checks that nothing is exported, and nothing fails, when metrics are disabled.
+func TestExportDisabled(t *testing.T) {
+	t.Setenv("OTEL_METRICS_EXPORTER", "")
+	t.Setenv("OTEL_TRACES_EXPORTER", "file")
+	// Would fail to connect, if metrics were sent.
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://127.0.0.1:1")
+	if err := Export(Job{Total: time.Minute}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -28,6 +28,8 @@ type Finished struct {
 	Timestamp *int64 `json:"timestamp,omitempty"`
 	// Passed is true when the job completes successfully.
 	Passed *bool `json:"passed"`
+	// Result is the result of the job ("SUCCESS", "ABORTED", or "FAILURE").
+	Result string `json:"result,omitempty"`
 }
 
 type Metadata map[string]interface{}
@@ -111,8 +113,31 @@ type Event struct {
 type ProwJob struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              ProwJobSpec   `json:"spec,omitempty"`
 	Status            ProwJobStatus `json:"status,omitempty"`
 }
+type ProwJobSpec struct {
+	// Cluster is which Kubernetes cluster is used to run the job
+	Cluster string `json:"cluster,omitempty"`
+	// Refs is the code under test, determined at runtime by Prow itself
+	Refs *Refs `json:"refs,omitempty"`
+	// PodSpec provides the basis for running the test under a Kubernetes agent
+	PodSpec *PodSpec `json:"pod_spec,omitempty"`
+}
+
+type PodSpec struct {
+	Containers []Container `json:"containers"`
+}
+
+type Container struct {
+	Name string   `json:"name"`
+	Env  []EnvVar `json:"env,omitempty"`
+}
+
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
 type ProwJobStatus struct {
 	// StartTime is equal to the creation time of the ProwJob
 	StartTime metav1.Time `json:"startTime,omitempty"`
@@ -231,6 +256,8 @@ type Refs struct {
 	// BaseLink is a link to the commit identified by BaseSHA.
 	BaseLink string `json:"base_link,omitempty"`
 
+	Pulls []Pull `json:"pulls,omitempty"`
+
 	// PathAlias is the location under <root-dir>/src
 	// where this repository is cloned. If this is not
 	// set, <root-dir>/src/github.com/org/repo will be
@@ -257,3 +284,10 @@ type Refs struct {
 	// The git fetch <remote> <BaseRef> call occurs regardless.
 	SkipFetchHead bool `json:"skip_fetch_head,omitempty"`
 }
+
+// Pull describes a pull request at a particular point in time.
+type Pull struct {
+	Number int    `json:"number"`
+	Author string `json:"author"`
+	SHA    string `json:"sha"`
+}
@@ -0,0 +1,84 @@
+// Package otlpjson implements the OTLP JSON encoding of trace requests.
+//
+// This is almost the standard protobuf JSON mapping, except that trace and span IDs are encoded as hex
+// rather than base64, and enums are encoded as integers.
+// See https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding.
+package otlpjson
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// idFields are the fields holding trace or span IDs, which OTLP encodes as hex.
+var idFields = map[string]bool{
+	"traceId":      true,
+	"spanId":       true,
+	"parentSpanId": true,
+}
+
+// Marshal encodes a request as OTLP JSON.
+func Marshal(req *coltracepb.ExportTraceServiceRequest) ([]byte, error) {
+	b, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	return convertIDs(b, func(s string) (string, error) {
+		b, err := base64.StdEncoding.DecodeString(s)
+		return hex.EncodeToString(b), err
+	})
+}
+
+// Unmarshal decodes a request from OTLP JSON.
+func Unmarshal(b []byte, req *coltracepb.ExportTraceServiceRequest) error {
+	b, err := convertIDs(b, func(s string) (string, error) {
+		b, err := hex.DecodeString(s)
+		return base64.StdEncoding.EncodeToString(b), err
+	})
+	if err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(b, req)
+}
+
+// convertIDs re-encodes all ID fields in the JSON document b with conv.
+func convertIDs(b []byte, conv func(string) (string, error)) ([]byte, error) {
+	var doc any
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	if err := walk(doc, conv); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func walk(v any, conv func(string) (string, error)) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if s, ok := e.(string); ok && idFields[k] {
+				c, err := conv(s)
+				if err != nil {
+					return err
+				}
+				v[k] = c
+				continue
+			}
+			if err := walk(e, conv); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if err := walk(e, conv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
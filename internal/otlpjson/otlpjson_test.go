@@ -0,0 +1,109 @@
+package otlpjson
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	traceID = []byte{0x0b, 0x8a, 0x4d, 0x4e, 0x1b, 0x3c, 0x11, 0xee, 0x9a, 0x26, 0x5a, 0x3f, 0x0b, 0x7d, 0x0c, 0x41}
+	spanID  = []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	otherID = []byte{0xf0, 0xe0, 0xd0, 0xc0, 0xb0, 0xa0, 0x90, 0x80}
+)
+
+func request(spans ...*tracepb.Span) *coltracepb.ExportTraceServiceRequest {
+	return &coltracepb.ExportTraceServiceRequest{ResourceSpans: []*tracepb.ResourceSpans{{
+		ScopeSpans: []*tracepb.ScopeSpans{{Spans: spans}},
+	}}}
+}
+
+func TestMarshal(t *testing.T) {
+	cases := []struct {
+		name string
+		span *tracepb.Span
+		want string
+	}{
+		{
+			name: "ids as hex",
+			span: &tracepb.Span{TraceId: traceID, SpanId: spanID, ParentSpanId: otherID, Name: "job"},
+			want: `{"traceId": "0b8a4d4e1b3c11ee9a265a3f0b7d0c41", "spanId": "0102030405060708", "parentSpanId": "f0e0d0c0b0a09080", "name": "job"}`,
+		},
+		{
+			name: "int64 as string",
+			span: &tracepb.Span{
+				TraceId:           traceID,
+				SpanId:            spanID,
+				StartTimeUnixNano: 1688169600123456789,
+				EndTimeUnixNano:   1688169660000000000,
+				Attributes: []*commonpb.KeyValue{{
+					Key:   "container.exit_code",
+					Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: 137}},
+				}},
+			},
+			want: `{"traceId": "0b8a4d4e1b3c11ee9a265a3f0b7d0c41", "spanId": "0102030405060708",
+				"startTimeUnixNano": "1688169600123456789", "endTimeUnixNano": "1688169660000000000",
+				"attributes": [{"key": "container.exit_code", "value": {"intValue": "137"}}]}`,
+		},
+		{
+			name: "enums as integers",
+			span: &tracepb.Span{
+				TraceId: traceID,
+				SpanId:  spanID,
+				Kind:    tracepb.Span_SPAN_KIND_INTERNAL,
+				Status:  &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR, Message: "exit code 1"},
+			},
+			want: `{"traceId": "0b8a4d4e1b3c11ee9a265a3f0b7d0c41", "spanId": "0102030405060708",
+				"kind": 1, "status": {"code": 2, "message": "exit code 1"}}`,
+		},
+		{
+			name: "link ids as hex",
+			span: &tracepb.Span{
+				TraceId: traceID,
+				SpanId:  spanID,
+				Links:   []*tracepb.Span_Link{{TraceId: traceID, SpanId: otherID}},
+			},
+			want: `{"traceId": "0b8a4d4e1b3c11ee9a265a3f0b7d0c41", "spanId": "0102030405060708",
+				"links": [{"traceId": "0b8a4d4e1b3c11ee9a265a3f0b7d0c41", "spanId": "f0e0d0c0b0a09080"}]}`,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := request(tt.span)
+			b, err := Marshal(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want any
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(`{"resourceSpans": [{"scopeSpans": [{"spans": [`+tt.want+`]}]}]}`), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %s", b)
+			}
+
+			back := &coltracepb.ExportTraceServiceRequest{}
+			if err := Unmarshal(b, back); err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(back, req) {
+				t.Fatalf("round trip changed the request: got %v, want %v", back, req)
+			}
+		})
+	}
+}
+
+func TestUnmarshalInvalidID(t *testing.T) {
+	err := Unmarshal([]byte(`{"resourceSpans": [{"scopeSpans": [{"spans": [{"traceId": "not hex"}]}]}]}`), &coltracepb.ExportTraceServiceRequest{})
+	if err == nil {
+		t.Fatal("expected an error for a non-hex trace ID")
+	}
+}
@@ -0,0 +1,109 @@
+package render
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/span"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// chromeEvent is an event in the Chrome Trace Event Format.
+// See https://docs.google.com/document/d/1CvAClvFfyA5R-PhYUmn5OOQtYMH4h6I0nSsKchNAySU.
+type chromeEvent struct {
+	Name  string         `json:"name"`
+	Phase string         `json:"ph"`
+	PID   int            `json:"pid"`
+	TID   int            `json:"tid"`
+	TS    float64        `json:"ts"`
+	Dur   float64        `json:"dur,omitempty"`
+	Scope string         `json:"s,omitempty"`
+	Cat   string         `json:"cat,omitempty"`
+	Args  map[string]any `json:"args,omitempty"`
+}
+
+type chromeTrace struct {
+	TraceEvents     []chromeEvent `json:"traceEvents"`
+	DisplayTimeUnit string        `json:"displayTimeUnit"`
+}
+
+// Chrome writes the trace in the Chrome Trace Event Format, which can be loaded in chrome://tracing or
+// the Perfetto UI. Spans are written as complete events and span events as instant events. Each
+// container of the pod gets its own thread lane.
+func Chrome(w io.Writer, root *span.Span) error {
+	c := &chromeWriter{lanes: map[string]int{}}
+	c.lane("job")
+	c.add(root, 0)
+	c.events = append(c.events, chromeEvent{
+		Name: "process_name", Phase: "M", PID: 1,
+		Args: map[string]any{"name": title(root)},
+	})
+	return json.NewEncoder(w).Encode(chromeTrace{TraceEvents: c.events, DisplayTimeUnit: "ms"})
+}
+
+type chromeWriter struct {
+	events []chromeEvent
+	lanes  map[string]int
+}
+
+// lane returns the thread ID for a lane, creating it if needed.
+func (c *chromeWriter) lane(name string) int {
+	if tid, f := c.lanes[name]; f {
+		return tid
+	}
+	tid := len(c.lanes)
+	c.lanes[name] = tid
+	c.events = append(c.events,
+		chromeEvent{Name: "thread_name", Phase: "M", PID: 1, TID: tid, Args: map[string]any{"name": name}},
+		chromeEvent{Name: "thread_sort_index", Phase: "M", PID: 1, TID: tid, Args: map[string]any{"sort_index": tid}},
+	)
+	return tid
+}
+
+func (c *chromeWriter) add(s *span.Span, tid int) {
+	if strings.HasPrefix(s.Name, "container/") || strings.HasPrefix(s.Name, "init/") {
+		tid = c.lane(s.Name)
+	}
+	args := attrArgs(s.Attributes)
+	if s.Failed() {
+		args["error"] = s.Status.Description
+	}
+	c.events = append(c.events, chromeEvent{
+		Name:  s.Name,
+		Phase: "X",
+		PID:   1,
+		TID:   tid,
+		TS:    micros(s.Start),
+		Dur:   float64(s.Duration().Nanoseconds()) / 1e3,
+		Args:  args,
+	})
+	for _, ev := range s.Events {
+		c.events = append(c.events, chromeEvent{
+			Name:  ev.Name,
+			Phase: "i",
+			Scope: "t",
+			PID:   1,
+			TID:   tid,
+			TS:    micros(ev.Time),
+			Cat:   "event",
+			Args:  attrArgs(ev.Attributes),
+		})
+	}
+	for _, child := range s.Children {
+		c.add(child, tid)
+	}
+}
+
+func attrArgs(attrs []attribute.KeyValue) map[string]any {
+	res := map[string]any{}
+	for _, a := range attrs {
+		res[string(a.Key)] = a.Value.AsInterface()
+	}
+	return res
+}
+
+func micros(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e3
+}
@@ -0,0 +1,34 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/howardjohn/prow-tracing/internal/span"
+)
+
+// CriticalPath writes the spans on the critical path of a trace as an indented tree, showing how much
+// of the critical path was spent in each and its share of the whole trace.
+func CriticalPath(w io.Writer, root *span.Span) error {
+	critical := span.CriticalTime(root, span.CriticalPath(root))
+	start, _ := root.Bounds()
+	total := root.Duration()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SPAN\tSTART\tDURATION\tCRITICAL\t% TOTAL")
+	root.Walk(func(s, _ *span.Span, depth int) bool {
+		d, f := critical[s]
+		if !f {
+			return false
+		}
+		share := "-"
+		if total > 0 {
+			share = fmt.Sprintf("%.1f%%", 100*float64(d)/float64(total))
+		}
+		name := strings.Repeat("  ", depth) + s.Name
+		fmt.Fprintln(tw, strings.Join([]string{name, "+" + formatDuration(s.Start.Sub(start)), formatDuration(s.Duration()), formatDuration(d), share}, "\t"))
+		return true
+	})
+	return tw.Flush()
+}
@@ -0,0 +1,104 @@
+package render
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/span"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+//go:embed report.html
+var reportTemplate string
+
+var reportTmpl = template.Must(template.New("report").Parse(reportTemplate))
+
+type htmlReport struct {
+	Title    string
+	Start    string
+	Duration string
+	Rows     []htmlRow
+}
+
+type htmlRow struct {
+	Name       string
+	Depth      int
+	Left       float64
+	Width      float64
+	Offset     string
+	Duration   string
+	Error      string
+	Attributes []htmlAttr
+	Events     []htmlEvent
+}
+
+type htmlEvent struct {
+	Name       string
+	Left       float64
+	Offset     string
+	Attributes []htmlAttr
+}
+
+type htmlAttr struct {
+	Key   string
+	Value string
+}
+
+// HTML writes the trace as a single static HTML page, showing a waterfall of all spans. Details such as
+// attributes and events are shown on hover. No external resources are referenced, so the page can be
+// uploaded as a job artifact.
+func HTML(w io.Writer, root *span.Span) error {
+	start, end := root.Bounds()
+	total := end.Sub(start)
+	report := htmlReport{
+		Title:    title(root),
+		Start:    start.UTC().Format(time.RFC3339),
+		Duration: formatDuration(total),
+	}
+	pct := func(t time.Time) float64 {
+		if total <= 0 {
+			return 0
+		}
+		return 100 * float64(t.Sub(start)) / float64(total)
+	}
+	root.Walk(func(s, _ *span.Span, depth int) bool {
+		row := htmlRow{
+			Name:       s.Name,
+			Depth:      depth,
+			Left:       pct(s.Start),
+			Width:      pct(s.End) - pct(s.Start),
+			Offset:     formatDuration(s.Start.Sub(start)),
+			Duration:   formatDuration(s.Duration()),
+			Attributes: htmlAttrs(s.Attributes),
+		}
+		if s.Failed() {
+			row.Error = s.Status.Description
+			if row.Error == "" {
+				row.Error = "error"
+			}
+		}
+		for _, ev := range s.Events {
+			row.Events = append(row.Events, htmlEvent{
+				Name:       ev.Name,
+				Left:       pct(ev.Time),
+				Offset:     formatDuration(ev.Time.Sub(start)),
+				Attributes: htmlAttrs(ev.Attributes),
+			})
+		}
+		report.Rows = append(report.Rows, row)
+		return true
+	})
+	return reportTmpl.Execute(w, report)
+}
+
+func htmlAttrs(attrs []attribute.KeyValue) []htmlAttr {
+	res := make([]htmlAttr, 0, len(attrs))
+	for _, a := range attrs {
+		res = append(res, htmlAttr{Key: string(a.Key), Value: a.Value.Emit()})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Key < res[j].Key })
+	return res
+}
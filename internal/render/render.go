@@ -0,0 +1,31 @@
+// Package render converts job traces into formats that can be viewed without a tracing backend.
+package render
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/span"
+)
+
+// title returns a name for the trace, preferring the job name.
+func title(root *span.Span) string {
+	if v, ok := root.Attribute("prow.k8s.io/job"); ok {
+		return v.Emit()
+	}
+	return root.Name
+}
+
+// formatDuration formats a duration for display, with precision appropriate to its magnitude.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Minute:
+		return d.Round(time.Second).String()
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return fmt.Sprint(d)
+	}
+}
@@ -0,0 +1,157 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/span"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var start = time.Date(2023, time.July, 1, 0, 0, 0, 1500, time.UTC)
+
+// testTrace returns a small trace: a job that cloned, then ran a failing test.
+func testTrace() *span.Span {
+	root := span.New("job", start, start.Add(100*time.Second))
+	root.SetAttributes(attribute.String("prow.k8s.io/job", "unit-tests"))
+	root.Child("init/clonerefs", start, start.Add(10*time.Second))
+	test := root.Child("container/test", start.Add(10*time.Second), start.Add(90*time.Second))
+	test.Event("Ready", start.Add(10*time.Second))
+	step := test.Child("go test <./...>", start.Add(20*time.Second), start.Add(80*time.Second))
+	step.SetAttributes(attribute.Int("exit_code", 1))
+	step.SetError("exit code 1")
+	return root
+}
+
+func TestChrome(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Chrome(&buf, testTrace()); err != nil {
+		t.Fatal(err)
+	}
+	var got chromeTrace
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	complete := map[string]chromeEvent{}
+	lanes := map[int]string{}
+	var ready chromeEvent
+	for _, ev := range got.TraceEvents {
+		if ev.PID != 1 {
+			t.Errorf("%v: got pid %v, want 1", ev.Name, ev.PID)
+		}
+		switch {
+		case ev.Phase == "X":
+			complete[ev.Name] = ev
+		case ev.Name == "thread_name":
+			lanes[ev.TID] = ev.Args["name"].(string)
+		case ev.Name == "Ready":
+			ready = ev
+		}
+	}
+
+	job := complete["job"]
+	if want := float64(start.UnixNano()) / 1e3; job.TS != want {
+		t.Errorf("job: got ts %v, want %v microseconds", job.TS, want)
+	}
+	if job.Dur != 100e6 {
+		t.Errorf("job: got dur %v, want 100s in microseconds", job.Dur)
+	}
+	for name, lane := range map[string]string{
+		"job":             "job",
+		"init/clonerefs":  "init/clonerefs",
+		"container/test":  "container/test",
+		"go test <./...>": "container/test",
+	} {
+		if got := lanes[complete[name].TID]; got != lane {
+			t.Errorf("%v: got lane %q, want %q", name, got, lane)
+		}
+	}
+	if ready.Phase != "i" || ready.TID != complete["container/test"].TID || ready.TS != complete["container/test"].TS {
+		t.Errorf("got Ready event %+v, want an instant event at the start of container/test", ready)
+	}
+	if got := complete["go test <./...>"].Args["error"]; got != "exit code 1" {
+		t.Errorf("got error arg %v, want exit code 1", got)
+	}
+}
+
+func TestText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Text(&buf, testTrace(), TextOptions{Waterfall: true, Width: 10}); err != nil {
+		t.Fatal(err)
+	}
+	want := `SPAN                                      START  DURATION  % PARENT  WATERFALL
+job                                       +0s    1m40s     -         |##########|
+  init/clonerefs                          +0s    10s       10.0%     |#         |
+  container/test                          +10s   1m20s     80.0%     | ######## |
+    go test <./...> [ERROR: exit code 1]  +20s   1m0s      75.0%     |  ######  |
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestBar(t *testing.T) {
+	end := start.Add(100 * time.Second)
+	cases := []struct {
+		name     string
+		from, to time.Time
+		want     string
+	}{
+		{"whole", start, end, "|##########|"},
+		{"short spans are visible", start.Add(50 * time.Second), start.Add(50*time.Second + time.Millisecond), "|     #    |"},
+		{"ending after the trace", start.Add(95 * time.Second), start.Add(200 * time.Second), "|         #|"},
+		{"starting after the trace", start.Add(200 * time.Second), start.Add(300 * time.Second), "|         #|"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bar(start, end, tt.from, tt.to, 10); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := HTML(&buf, testTrace()); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"<title>unit-tests</title>",
+		"Started 2023-07-01T00:00:00Z, took 1m40s",
+		// container/test runs from 10% to 90% of the trace.
+		`style="left: 10.0000%; width: 80.0000%"`,
+		`<div class="bar error" style="left: 20.0000%; width: 60.0000%"`,
+		"<td>error</td><td>exit code 1</td>",
+		"<td>exit_code</td><td>1</td>",
+		// Span names are escaped.
+		"go test &lt;./...&gt;",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+	if strings.Contains(got, "<./...>") {
+		t.Error("span name was not escaped")
+	}
+}
+
+func TestCriticalPath(t *testing.T) {
+	var buf bytes.Buffer
+	if err := CriticalPath(&buf, testTrace()); err != nil {
+		t.Fatal(err)
+	}
+	want := `SPAN                 START  DURATION  CRITICAL  % TOTAL
+job                  +0s    1m40s     1m40s     100.0%
+  init/clonerefs     +0s    10s       10s       10.0%
+  container/test     +10s   1m20s     1m20s     80.0%
+    go test <./...>  +20s   1m0s      1m0s      60.0%
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
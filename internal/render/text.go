@@ -0,0 +1,78 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/span"
+)
+
+// TextOptions configures the terminal rendering of a trace.
+type TextOptions struct {
+	// Waterfall adds a bar to each span showing when it ran relative to the whole trace.
+	Waterfall bool
+	// Width is the width of the waterfall bars, in characters.
+	Width int
+}
+
+// Text writes the trace as an indented tree, showing each span's start offset from the beginning
+// of the trace, duration, and share of its parent's duration. Failed spans are marked with their error.
+func Text(w io.Writer, root *span.Span, opts TextOptions) error {
+	if opts.Width <= 0 {
+		opts.Width = 60
+	}
+	start, end := root.Bounds()
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := []string{"SPAN", "START", "DURATION", "% PARENT"}
+	if opts.Waterfall {
+		header = append(header, "WATERFALL")
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	root.Walk(func(s, parent *span.Span, depth int) bool {
+		share := "-"
+		if parent != nil && parent.Duration() > 0 {
+			share = fmt.Sprintf("%.1f%%", 100*float64(s.Duration())/float64(parent.Duration()))
+		}
+		name := strings.Repeat("  ", depth) + s.Name
+		if s.Failed() {
+			name += " [ERROR"
+			if d := s.Status.Description; d != "" {
+				name += ": " + d
+			}
+			name += "]"
+		}
+		cols := []string{name, "+" + formatDuration(s.Start.Sub(start)), formatDuration(s.Duration()), share}
+		if opts.Waterfall {
+			cols = append(cols, bar(start, end, s.Start, s.End, opts.Width))
+		}
+		fmt.Fprintln(tw, strings.Join(cols, "\t"))
+		return true
+	})
+	return tw.Flush()
+}
+
+// bar draws an ASCII bar of the given width, filled for the portion of [start, end] covered by [from, to].
+func bar(start, end, from, to time.Time, width int) string {
+	total := end.Sub(start)
+	if total <= 0 {
+		return "|" + strings.Repeat(" ", width) + "|"
+	}
+	pos := func(t time.Time) int {
+		return int(float64(width) * float64(t.Sub(start)) / float64(total))
+	}
+	l, r := pos(from), pos(to)
+	if r <= l {
+		// Always draw something, so short spans are visible.
+		r = l + 1
+	}
+	if r > width {
+		r = width
+		if l >= r {
+			l = r - 1
+		}
+	}
+	return "|" + strings.Repeat(" ", l) + strings.Repeat("#", r-l) + strings.Repeat(" ", width-r) + "|"
+}
@@ -0,0 +1,94 @@
+package span
+
+import (
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// CriticalPathKey marks spans on the critical path of a trace.
+const CriticalPathKey = attribute.Key("critical_path")
+
+// Segment is a stretch of time on the critical path, attributed to a single span.
+type Segment struct {
+	Span       *Span
+	Start, End time.Time
+}
+
+func (s Segment) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// CriticalPath returns the chain of work that determined how long root took, in chronological order.
+// Working back from the end of a span, the child that finished last is on the critical path, and
+// so on from when that child started; any time not covered by a child is attributed to the span
+// itself. Children running in parallel with the one on the critical path are not on it, as finishing
+// them sooner would not have finished the parent any sooner.
+func CriticalPath(root *Span) []Segment {
+	var res []Segment
+	criticalPath(root, root.End, &res)
+	// Segments were found working backwards.
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res
+}
+
+func criticalPath(s *Span, end time.Time, res *[]Segment) {
+	cur := end
+	if s.End.Before(cur) {
+		cur = s.End
+	}
+	children := append([]*Span{}, s.Children...)
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].End.After(children[j].End)
+	})
+	for cur.After(s.Start) {
+		// Find the child that was last running at cur.
+		var next *Span
+		for _, c := range children {
+			if c.Start.Before(cur) && c.End.After(c.Start) {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			*res = append(*res, Segment{Span: s, Start: s.Start, End: cur})
+			return
+		}
+		childEnd := next.End
+		if childEnd.After(cur) {
+			childEnd = cur
+		}
+		if childEnd.Before(cur) {
+			*res = append(*res, Segment{Span: s, Start: childEnd, End: cur})
+		}
+		criticalPath(next, childEnd, res)
+		cur = next.Start
+	}
+}
+
+// CriticalTime returns how much of the critical path was spent in each span on it, including time spent
+// in its descendants. Spans are on the critical path if they or any of their descendants have a segment.
+func CriticalTime(root *Span, path []Segment) map[*Span]time.Duration {
+	parents := map[*Span]*Span{}
+	root.Walk(func(s, parent *Span, _ int) bool {
+		parents[s] = parent
+		return true
+	})
+	res := map[*Span]time.Duration{}
+	for _, seg := range path {
+		for s := seg.Span; s != nil; s = parents[s] {
+			res[s] += seg.Duration()
+		}
+	}
+	return res
+}
+
+// TagCriticalPath marks each span on the critical path of root.
+func TagCriticalPath(root *Span) {
+	for s := range CriticalTime(root, CriticalPath(root)) {
+		s.SetAttributes(CriticalPathKey.Bool(true))
+	}
+}
@@ -0,0 +1,58 @@
+package span
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestCriticalPath(t *testing.T) {
+	root := New("job", at(0), at(100))
+	pod := root.Child("pod", at(5), at(95))
+	clone := pod.Child("clone", at(5), at(20))
+	// Runs alongside test, but starts later and finishes first, so is not on the critical path.
+	sidecar := pod.Child("sidecar", at(30), at(80))
+	test := pod.Child("test", at(25), at(90))
+
+	type segment struct {
+		name       string
+		start, end float64
+	}
+	var got []segment
+	for _, s := range CriticalPath(root) {
+		got = append(got, segment{s.Span.Name, s.Start.Sub(epoch).Seconds(), s.End.Sub(epoch).Seconds()})
+	}
+	want := []segment{
+		{"job", 0, 5},
+		{"clone", 5, 20},
+		{"pod", 20, 25},
+		{"test", 25, 90},
+		{"pod", 90, 95},
+		{"job", 95, 100},
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	times := CriticalTime(root, CriticalPath(root))
+	if got := times[root]; got != 100*time.Second {
+		t.Errorf("root: got %v on the critical path, want all of it", got)
+	}
+	if got := times[pod]; got != 90*time.Second {
+		t.Errorf("pod: got %v on the critical path, want 90s", got)
+	}
+	if _, f := times[sidecar]; f {
+		t.Errorf("sidecar is on the critical path")
+	}
+
+	TagCriticalPath(root)
+	for _, s := range []*Span{root, pod, clone, test} {
+		if _, ok := s.Attribute(CriticalPathKey); !ok {
+			t.Errorf("span %q not tagged as on the critical path", s.Name)
+		}
+	}
+	if _, ok := sidecar.Attribute(CriticalPathKey); ok {
+		t.Errorf("sidecar tagged as on the critical path")
+	}
+}
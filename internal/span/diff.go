@@ -0,0 +1,112 @@
+package span
+
+import (
+	"sort"
+	"time"
+)
+
+// Difference is how a span path differs between two traces.
+type Difference struct {
+	Path string
+	// A and B are the total durations of the spans at Path in each trace.
+	A, B time.Duration
+	// InA and InB are set if the path is present in that trace.
+	InA, InB   bool
+	Attributes []AttributeChange
+}
+
+// Delta is how much longer the path took in B than in A.
+func (d Difference) Delta() time.Duration {
+	return d.B - d.A
+}
+
+// Impact is how much the path's difference in duration matters, regardless of its direction.
+func (d Difference) Impact() time.Duration {
+	if delta := d.Delta(); delta < 0 {
+		return -delta
+	}
+	return d.Delta()
+}
+
+// AttributeChange is an attribute that has different values in two traces. A missing attribute has an
+// empty value.
+type AttributeChange struct {
+	Key  string
+	A, B string
+}
+
+// Diff aligns the spans of two traces by path, returning the paths that differ in duration, presence, or
+// attributes, most impactful first. Where a path has many spans in a trace, their durations are summed
+// and the attributes of the first are compared.
+func Diff(a, b *Span) []Difference {
+	type entry struct {
+		duration time.Duration
+		attrs    map[string]string
+	}
+	collect := func(root *Span) map[string]*entry {
+		res := map[string]*entry{}
+		paths := Paths(root)
+		root.Walk(func(s, _ *Span, _ int) bool {
+			p := paths[s]
+			e, f := res[p]
+			if !f {
+				e = &entry{attrs: map[string]string{}}
+				for _, kv := range s.Attributes {
+					e.attrs[string(kv.Key)] = kv.Value.Emit()
+				}
+				res[p] = e
+			}
+			e.duration += s.Duration()
+			return true
+		})
+		return res
+	}
+	as, bs := collect(a), collect(b)
+
+	paths := map[string]struct{}{}
+	for p := range as {
+		paths[p] = struct{}{}
+	}
+	for p := range bs {
+		paths[p] = struct{}{}
+	}
+	res := []Difference{}
+	for p := range paths {
+		d := Difference{Path: p}
+		ea, inA := as[p]
+		eb, inB := bs[p]
+		d.InA, d.InB = inA, inB
+		keys := map[string]struct{}{}
+		if inA {
+			d.A = ea.duration
+			for k := range ea.attrs {
+				keys[k] = struct{}{}
+			}
+		}
+		if inB {
+			d.B = eb.duration
+			for k := range eb.attrs {
+				keys[k] = struct{}{}
+			}
+		}
+		if inA && inB {
+			for k := range keys {
+				if va, vb := ea.attrs[k], eb.attrs[k]; va != vb {
+					d.Attributes = append(d.Attributes, AttributeChange{Key: k, A: va, B: vb})
+				}
+			}
+			sort.Slice(d.Attributes, func(i, j int) bool { return d.Attributes[i].Key < d.Attributes[j].Key })
+		}
+		if d.Delta() == 0 && inA == inB && len(d.Attributes) == 0 {
+			continue
+		}
+		res = append(res, d)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Impact() != res[j].Impact() {
+			return res[i].Impact() > res[j].Impact()
+		}
+		return res[i].Path < res[j].Path
+	})
+	return res
+}
@@ -0,0 +1,89 @@
+package span
+
+import (
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Unaccounted is the name of the synthetic spans added by FillGaps.
+const Unaccounted = "unaccounted"
+
+const (
+	// SyntheticKey marks spans that were inferred, rather than recorded.
+	SyntheticKey = attribute.Key("synthetic")
+	// OutsideParentKey marks spans that start before or end after their parent.
+	OutsideParentKey = attribute.Key("outside_parent")
+	// EarlyKey and LateKey record, in milliseconds, how far a span extends outside its parent.
+	EarlyKey = attribute.Key("outside_parent.early_ms")
+	LateKey  = attribute.Key("outside_parent.late_ms")
+)
+
+// FillGaps adds an Unaccounted child to each span wherever at least min of its duration is not covered
+// by any of its children. Spans without children are left alone, as there is nothing to account for
+// their time.
+func FillGaps(s *Span, min time.Duration) {
+	if len(s.Children) == 0 {
+		return
+	}
+	for _, c := range s.Children {
+		FillGaps(c, min)
+	}
+	var gaps []*Span
+	cur := s.Start
+	for _, c := range byStart(s.Children) {
+		if c.Start.Sub(cur) >= min {
+			gaps = append(gaps, unaccounted(cur, c.Start))
+		}
+		if c.End.After(cur) {
+			cur = c.End
+		}
+	}
+	if s.End.Sub(cur) >= min {
+		gaps = append(gaps, unaccounted(cur, s.End))
+	}
+	if len(gaps) > 0 {
+		s.Children = byStart(append(s.Children, gaps...))
+	}
+}
+
+func unaccounted(start, end time.Time) *Span {
+	s := New(Unaccounted, start, end)
+	s.SetAttributes(SyntheticKey.Bool(true))
+	return s
+}
+
+// byStart returns spans ordered by start time, keeping the existing order of spans starting together.
+func byStart(spans []*Span) []*Span {
+	res := append([]*Span{}, spans...)
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Start.Before(res[j].Start)
+	})
+	return res
+}
+
+// FlagOverlaps marks each span that starts before or ends after its parent, which is usually due to
+// clock skew between the sources the spans were built from. It returns the flagged spans.
+func FlagOverlaps(root *Span) []*Span {
+	var flagged []*Span
+	root.Walk(func(s, parent *Span, _ int) bool {
+		if parent == nil {
+			return true
+		}
+		early, late := parent.Start.Sub(s.Start), s.End.Sub(parent.End)
+		if early <= 0 && late <= 0 {
+			return true
+		}
+		s.SetAttributes(OutsideParentKey.Bool(true))
+		if early > 0 {
+			s.SetAttributes(EarlyKey.Int64(early.Milliseconds()))
+		}
+		if late > 0 {
+			s.SetAttributes(LateKey.Int64(late.Milliseconds()))
+		}
+		flagged = append(flagged, s)
+		return true
+	})
+	return flagged
+}
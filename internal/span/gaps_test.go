@@ -0,0 +1,50 @@
+package span
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestFillGaps(t *testing.T) {
+	root := New("job", at(0), at(100))
+	a := root.Child("a", at(10), at(40))
+	// Overlaps a, so there is no gap between them.
+	root.Child("b", at(30), at(60))
+	// Starts within min of b ending.
+	root.Child("c", at(60.5), at(90))
+	a.Child("a1", at(10), at(20))
+	leaf := root.Children[1]
+
+	FillGaps(root, time.Second)
+
+	type gap struct{ start, end float64 }
+	gaps := func(s *Span) []gap {
+		var res []gap
+		for _, c := range s.Children {
+			if c.Name != Unaccounted {
+				continue
+			}
+			if v, ok := c.Attribute(SyntheticKey); !ok || !v.AsBool() {
+				t.Errorf("unaccounted span is not marked synthetic")
+			}
+			res = append(res, gap{c.Start.Sub(epoch).Seconds(), c.End.Sub(epoch).Seconds()})
+		}
+		return res
+	}
+	if got, want := gaps(root), []gap{{0, 10}, {90, 100}}; !slices.Equal(got, want) {
+		t.Errorf("root: got gaps %v, want %v", got, want)
+	}
+	if got, want := gaps(a), []gap{{20, 40}}; !slices.Equal(got, want) {
+		t.Errorf("a: got gaps %v, want %v", got, want)
+	}
+	if len(leaf.Children) != 0 {
+		t.Errorf("span without children got %d children", len(leaf.Children))
+	}
+	for i := 1; i < len(root.Children); i++ {
+		if root.Children[i].Start.Before(root.Children[i-1].Start) {
+			t.Fatalf("children not ordered by start")
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package span
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// ShiftKey records, in milliseconds, how far Nest moved a span and its descendants. Positive values
+	// are later.
+	ShiftKey = attribute.Key("skew.shift_ms")
+	// ClampKey records, in milliseconds, how much of a span Nest trimmed off.
+	ClampKey = attribute.Key("skew.clamp_ms")
+)
+
+// Nest moves spans that extend outside their parent back inside it, returning the adjusted spans. A span
+// that fits inside its parent is shifted along with its descendants, as the usual cause is an offset
+// between the clocks the two were recorded with. Otherwise, it is clamped to its parent.
+func Nest(root *Span) []*Span {
+	var adjusted []*Span
+	root.Walk(func(s, parent *Span, _ int) bool {
+		if parent == nil {
+			return true
+		}
+		early, late := parent.Start.Sub(s.Start), s.End.Sub(parent.End)
+		if early <= 0 && late <= 0 {
+			return true
+		}
+		if s.Duration() <= parent.Duration() {
+			shift := early
+			if late > 0 {
+				shift = -late
+			}
+			s.shift(shift)
+			s.SetAttributes(ShiftKey.Int64(shift.Milliseconds()))
+		} else {
+			var trimmed time.Duration
+			if early > 0 {
+				s.Start = parent.Start
+				trimmed += early
+			}
+			if late > 0 {
+				s.End = parent.End
+				trimmed += late
+			}
+			s.SetAttributes(ClampKey.Int64(trimmed.Milliseconds()))
+		}
+		adjusted = append(adjusted, s)
+		return true
+	})
+	return adjusted
+}
+
+// shift moves the span and its descendants by d.
+func (s *Span) shift(d time.Duration) {
+	s.Walk(func(c, _ *Span, _ int) bool {
+		c.Start = c.Start.Add(d)
+		c.End = c.End.Add(d)
+		for i := range c.Events {
+			c.Events[i].Time = c.Events[i].Time.Add(d)
+		}
+		return true
+	})
+}
@@ -0,0 +1,56 @@
+package span
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNest(t *testing.T) {
+	root := New("job", at(0), at(100))
+	// Fits inside its parent, but was recorded with a clock 5s ahead: shifted, with its children.
+	early := root.Child("early", at(-5), at(20))
+	early.Child("child", at(-5), at(0))
+	early.Event("event", at(-5))
+	// Ends after its parent: shifted back.
+	late := root.Child("late", at(90), at(110))
+	// Longer than its parent: clamped.
+	long := root.Child("long", at(-1), at(102))
+	inside := root.Child("inside", at(10), at(20))
+
+	adjusted := Nest(root)
+	if len(adjusted) != 3 {
+		t.Fatalf("got %d adjusted spans, want 3", len(adjusted))
+	}
+	check := func(s *Span, start, end float64) {
+		t.Helper()
+		if !s.Start.Equal(at(start)) || !s.End.Equal(at(end)) {
+			t.Errorf("span %q: got %v-%v, want %v-%v", s.Name, s.Start.Sub(epoch), s.End.Sub(epoch), at(start).Sub(epoch), at(end).Sub(epoch))
+		}
+	}
+	check(early, 0, 25)
+	check(early.Children[0], 0, 5)
+	check(late, 80, 100)
+	check(long, 0, 100)
+	check(inside, 10, 20)
+	if got := early.Events[0].Time; !got.Equal(at(0)) {
+		t.Errorf("event not shifted with its span: got %v", got.Sub(epoch))
+	}
+
+	attr := func(s *Span, key string) int64 {
+		for _, kv := range s.Attributes {
+			if string(kv.Key) == key {
+				return kv.Value.AsInt64()
+			}
+		}
+		return 0
+	}
+	if got := attr(early, string(ShiftKey)); got != (5 * time.Second).Milliseconds() {
+		t.Errorf("early: got shift %dms, want 5000ms", got)
+	}
+	if got := attr(late, string(ShiftKey)); got != (-10 * time.Second).Milliseconds() {
+		t.Errorf("late: got shift %dms, want -10000ms", got)
+	}
+	if got := attr(long, string(ClampKey)); got != (3 * time.Second).Milliseconds() {
+		t.Errorf("long: got clamp %dms, want 3000ms", got)
+	}
+}
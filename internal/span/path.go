@@ -0,0 +1,43 @@
+package span
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PathSeparator joins the names of a span's ancestors and its own into its path.
+const PathSeparator = " > "
+
+// Paths returns the path of each span in the trace, which identifies the same span across runs of a job.
+// A path is the names from the root down to the span, like "job > pod > init/clonerefs". Names are
+// joined as-is, as "/" is common in them, unless they contain the separator, in which case they are
+// quoted, so that two different spans never share a path by accident.
+func Paths(root *Span) map[*Span]string {
+	res := map[*Span]string{}
+	root.Walk(func(s, parent *Span, _ int) bool {
+		if parent == nil {
+			res[s] = pathElement(s.Name)
+		} else {
+			res[s] = res[parent] + PathSeparator + pathElement(s.Name)
+		}
+		return true
+	})
+	return res
+}
+
+func pathElement(name string) string {
+	if strings.Contains(name, PathSeparator) || strings.HasPrefix(name, `"`) {
+		return strconv.Quote(name)
+	}
+	return name
+}
+
+// Durations returns the total duration of the spans at each path in the trace.
+func Durations(root *Span) map[string]time.Duration {
+	res := map[string]time.Duration{}
+	for s, p := range Paths(root) {
+		res[p] += s.Duration()
+	}
+	return res
+}
@@ -0,0 +1,74 @@
+package span
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2023, time.July, 1, 0, 0, 0, 0, time.UTC)
+
+// at returns the time sec seconds into a test trace.
+func at(sec float64) time.Time {
+	return epoch.Add(time.Duration(sec * float64(time.Second)))
+}
+
+func TestPaths(t *testing.T) {
+	root := New("job", at(0), at(100))
+	pod := root.Child("pod", at(0), at(100))
+	clone := pod.Child("init/clonerefs", at(0), at(10))
+	fetch := clone.Child("git fetch", at(0), at(5))
+	test := pod.Child("container/test", at(10), at(100))
+	step := test.Child("test", at(10), at(90))
+	slash := step.Child("test/pilot", at(10), at(50))
+	nested := step.Child("test", at(50), at(60))
+	nestedPilot := nested.Child("pilot", at(50), at(60))
+	quoted := step.Child("a > b", at(60), at(70))
+	gap := root.Child(Unaccounted, at(0), at(1))
+
+	got := Paths(root)
+	want := map[*Span]string{
+		root:        "job",
+		pod:         "job > pod",
+		clone:       "job > pod > init/clonerefs",
+		fetch:       "job > pod > init/clonerefs > git fetch",
+		test:        "job > pod > container/test",
+		step:        "job > pod > container/test > test",
+		slash:       "job > pod > container/test > test > test/pilot",
+		nested:      "job > pod > container/test > test > test",
+		nestedPilot: "job > pod > container/test > test > test > pilot",
+		quoted:      `job > pod > container/test > test > "a > b"`,
+		gap:         "job > unaccounted",
+	}
+	if !reflect.DeepEqual(got, want) {
+		for s, p := range got {
+			if want[s] != p {
+				t.Errorf("span %q: got path %q, want %q", s.Name, p, want[s])
+			}
+		}
+	}
+
+	seen := map[string]*Span{}
+	for s, p := range got {
+		if other, f := seen[p]; f {
+			t.Errorf("spans %q and %q share path %q", s.Name, other.Name, p)
+		}
+		seen[p] = s
+	}
+}
+
+func TestDurations(t *testing.T) {
+	root := New("job", at(0), at(10))
+	clone := root.Child("clone/org/repo", at(0), at(4))
+	clone.Child("git config", at(0), at(1))
+	clone.Child("git config", at(1), at(3))
+	got := Durations(root)
+	want := map[string]time.Duration{
+		"job":                               10 * time.Second,
+		"job > clone/org/repo":              4 * time.Second,
+		"job > clone/org/repo > git config": 3 * time.Second,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+// Package span holds an in-memory model of a job trace. The trace is built as a tree of Spans, which can
+// then be inspected and transformed before being handed to sinks that export or render it.
+package span
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span is a single span of a trace, along with its children.
+type Span struct {
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes []attribute.KeyValue
+	Events     []Event
+	Status     Status
+	Links      []trace.Link
+	Children   []*Span
+
+	// ID is set for spans that were already assigned an ID elsewhere, such as spans recorded from inside
+	// the job. Otherwise, sinks assign their own.
+	ID trace.SpanID
+	// Exported is set for spans that were already exported live from inside the job. Sinks sending spans
+	// to a backend should skip these, but still process their children.
+	Exported bool
+}
+
+type Event struct {
+	Name       string
+	Time       time.Time
+	Attributes []attribute.KeyValue
+}
+
+type Status struct {
+	Code        codes.Code
+	Description string
+}
+
+// New returns a root span.
+func New(name string, start, end time.Time) *Span {
+	return &Span{Name: name, Start: start, End: end}
+}
+
+// Child adds a new child span, returning it.
+func (s *Span) Child(name string, start, end time.Time) *Span {
+	c := New(name, start, end)
+	s.Children = append(s.Children, c)
+	return c
+}
+
+// SetAttributes adds attributes to the span, replacing any existing attributes with the same key.
+func (s *Span) SetAttributes(attrs ...attribute.KeyValue) {
+	for _, a := range attrs {
+		replaced := false
+		for i, existing := range s.Attributes {
+			if existing.Key == a.Key {
+				s.Attributes[i] = a
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			s.Attributes = append(s.Attributes, a)
+		}
+	}
+}
+
+// Attribute returns the value of the attribute with the given key.
+func (s *Span) Attribute(key attribute.Key) (attribute.Value, bool) {
+	for _, a := range s.Attributes {
+		if a.Key == key {
+			return a.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// Event records an event on the span.
+func (s *Span) Event(name string, t time.Time, attrs ...attribute.KeyValue) {
+	s.Events = append(s.Events, Event{Name: name, Time: t, Attributes: attrs})
+}
+
+// SetError marks the span as failed.
+func (s *Span) SetError(description string) {
+	s.Status = Status{Code: codes.Error, Description: description}
+}
+
+// Failed returns true if the span is marked as failed.
+func (s *Span) Failed() bool {
+	return s.Status.Code == codes.Error
+}
+
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Walk calls fn for the span and all of its descendants, depth first. parent is nil for the span Walk
+// is called on. If fn returns false, the span's children are skipped.
+func (s *Span) Walk(fn func(s, parent *Span, depth int) bool) {
+	s.walk(nil, 0, fn)
+}
+
+func (s *Span) walk(parent *Span, depth int, fn func(s, parent *Span, depth int) bool) {
+	if !fn(s, parent, depth) {
+		return
+	}
+	for _, c := range s.Children {
+		c.walk(s, depth+1, fn)
+	}
+}
+
+// Bounds returns the earliest start and latest end of the span and all of its descendants.
+func (s *Span) Bounds() (time.Time, time.Time) {
+	start, end := s.Start, s.End
+	s.Walk(func(c, _ *Span, _ int) bool {
+		if c.Start.Before(start) {
+			start = c.Start
+		}
+		if c.End.After(end) {
+			end = c.End
+		}
+		return true
+	})
+	return start, end
+}
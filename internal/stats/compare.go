@@ -0,0 +1,116 @@
+package stats
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// CompareOptions configures when a change in duration is reported as a regression.
+type CompareOptions struct {
+	// Alpha is the significance level: the highest p-value reported as a regression.
+	Alpha float64
+	// Threshold is the smallest relative increase in median duration reported as a regression.
+	Threshold float64
+	// MinRuns is the fewest samples each window must have for a path to be tested.
+	MinRuns int
+}
+
+// Comparison contrasts the durations of a span path between a baseline and current window.
+type Comparison struct {
+	Path              string
+	Baseline, Current Summary
+	// Change is the relative change in median duration.
+	Change float64
+	// PValue is the probability of seeing durations at least this much slower if nothing had changed.
+	// It is 1 for paths with too few samples to test.
+	PValue    float64
+	Regressed bool
+}
+
+// Compare contrasts each span path found in both windows, ordered by regressions first, then by the
+// largest slowdown.
+func Compare(baseline, current Samples, opts CompareOptions) []Comparison {
+	res := []Comparison{}
+	for p, cur := range current {
+		base, f := baseline[p]
+		if !f {
+			continue
+		}
+		c := Comparison{
+			Path:     p,
+			Baseline: summarize(p, base),
+			Current:  summarize(p, cur),
+			PValue:   1,
+		}
+		if c.Baseline.P50 > 0 {
+			c.Change = float64(c.Current.P50-c.Baseline.P50) / float64(c.Baseline.P50)
+		}
+		if len(base) >= opts.MinRuns && len(cur) >= opts.MinRuns {
+			c.PValue = MannWhitney(base, cur)
+		}
+		c.Regressed = c.PValue <= opts.Alpha && c.Change >= opts.Threshold
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Regressed != res[j].Regressed {
+			return res[i].Regressed
+		}
+		if res[i].Change != res[j].Change {
+			return res[i].Change > res[j].Change
+		}
+		return res[i].Path < res[j].Path
+	})
+	return res
+}
+
+// MannWhitney returns the one-sided p-value of the Mann-Whitney U test that durations in b tend to be
+// longer than those in a. It uses the normal approximation, corrected for ties, so is only meaningful
+// with a handful of samples or more in each.
+func MannWhitney(a, b []time.Duration) float64 {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	type sample struct {
+		d     time.Duration
+		fromB bool
+	}
+	all := make([]sample, 0, len(a)+len(b))
+	for _, d := range a {
+		all = append(all, sample{d, false})
+	}
+	for _, d := range b {
+		all = append(all, sample{d, true})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].d < all[j].d })
+
+	// Rank the samples, giving tied samples the average of their ranks.
+	var rankB, ties float64
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].d == all[i].d {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if all[k].fromB {
+				rankB += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	n := n1 + n2
+	u := rankB - n2*(n2+1)/2
+	mean := n1 * n2 / 2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	// Apply a continuity correction, as U is discrete.
+	z := (u - mean - 0.5) / math.Sqrt(variance)
+	return 0.5 * math.Erfc(z/math.Sqrt2)
+}
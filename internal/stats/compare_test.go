@@ -0,0 +1,61 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func seconds(s ...int) []time.Duration {
+	res := []time.Duration{}
+	for _, v := range s {
+		res = append(res, time.Duration(v)*time.Second)
+	}
+	return res
+}
+
+func TestMannWhitney(t *testing.T) {
+	cases := []struct {
+		name     string
+		a, b     []time.Duration
+		min, max float64
+	}{
+		{"slower", seconds(10, 11, 12, 13, 14, 15, 16, 17), seconds(20, 21, 22, 23, 24, 25, 26, 27), 0, 0.001},
+		{"faster", seconds(20, 21, 22, 23, 24, 25, 26, 27), seconds(10, 11, 12, 13, 14, 15, 16, 17), 0.999, 1},
+		{"same", seconds(10, 12, 14, 16, 18, 20), seconds(11, 13, 15, 17, 19, 21), 0.2, 0.8},
+		{"all tied", seconds(10, 10, 10), seconds(10, 10, 10), 1, 1},
+		{"empty", nil, seconds(10), 1, 1},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if p := MannWhitney(tt.a, tt.b); p < tt.min || p > tt.max {
+				t.Fatalf("p = %v, want in [%v, %v]", p, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := Samples{
+		"container/test": seconds(600, 610, 590, 605, 595, 600),
+		"init/clonerefs": seconds(15, 16, 14, 15, 15, 16),
+		"pod/schedule":   seconds(2, 3),
+	}
+	cur := Samples{
+		"container/test": seconds(900, 910, 890, 905, 895, 900),
+		"init/clonerefs": seconds(15, 14, 16, 15, 16, 15),
+		"pod/schedule":   seconds(20, 30),
+	}
+	res := Compare(base, cur, CompareOptions{Alpha: 0.05, Threshold: 0.1, MinRuns: 5})
+	if len(res) != 3 {
+		t.Fatalf("got %d comparisons, want 3", len(res))
+	}
+	if res[0].Path != "container/test" || !res[0].Regressed {
+		t.Fatalf("want container/test to regress first, got %+v", res[0])
+	}
+	for _, c := range res[1:] {
+		if c.Regressed {
+			// pod/schedule is slower, but has too few runs to test.
+			t.Fatalf("want %v not to regress, got %+v", c.Path, c)
+		}
+	}
+}
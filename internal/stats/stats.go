@@ -0,0 +1,63 @@
+// Package stats summarizes span durations across many runs of a job.
+package stats
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Summary describes the distribution of the durations of a span across runs.
+type Summary struct {
+	Path  string
+	Count int
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Samples collects the durations of each span path, with one sample per run.
+type Samples map[string][]time.Duration
+
+// Add records the durations of a single run.
+func (s Samples) Add(durations map[string]time.Duration) {
+	for p, d := range durations {
+		s[p] = append(s[p], d)
+	}
+}
+
+// Summarize returns a Summary for each path, ordered by path.
+func (s Samples) Summarize() []Summary {
+	res := make([]Summary, 0, len(s))
+	for p, ds := range s {
+		res = append(res, summarize(p, ds))
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+	return res
+}
+
+func summarize(path string, ds []time.Duration) Summary {
+	sorted := append([]time.Duration{}, ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return Summary{
+		Path:  path,
+		Count: len(sorted),
+		P50:   Percentile(sorted, 50),
+		P90:   Percentile(sorted, 90),
+		P99:   Percentile(sorted, 99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// Percentile returns the pth percentile of sorted, using the nearest-rank method.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,160 @@
+// Package steps implements a simple file based API for recording spans from inside a running job.
+// Each invocation appends a Record to a file in $ARTIFACTS, which is uploaded with the rest of the
+// job artifacts and later merged into the job's trace.
+package steps
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// FileName is the name of the steps file, relative to $ARTIFACTS.
+const FileName = "steps.jsonl"
+
+type Phase string
+
+const (
+	Start Phase = "start"
+	End   Phase = "end"
+)
+
+// Record is a single line in the steps file.
+type Record struct {
+	// TraceID is the trace the step belongs to, derived from PROW_JOB_ID.
+	TraceID    string            `json:"traceId"`
+	Name       string            `json:"name"`
+	Phase      Phase             `json:"phase"`
+	Time       time.Time         `json:"time"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Span is a step reconstructed from its start and end records.
+type Span struct {
+	Name  string
+	Start time.Time
+	// End is zero if the step never ended, for instance if the job was killed.
+	End        time.Time
+	Attributes map[string]string
+	Children   []*Span
+}
+
+// Path returns the location of the steps file for the current job.
+func Path() (string, error) {
+	dir := os.Getenv("ARTIFACTS")
+	if dir == "" {
+		return "", fmt.Errorf("ARTIFACTS required")
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// Append writes a record to the end of the steps file, creating it if needed.
+func Append(path string, r Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Read parses a steps file, returning the top level spans for the given trace.
+// Steps are nested based on their timing: a step is a child of the innermost step that contains it.
+func Read(r io.Reader, traceID string) ([]*Span, error) {
+	open := map[string][]*Span{}
+	spans := []*Span{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, err
+		}
+		if rec.TraceID != traceID {
+			slog.Warn("skipping step from another trace", "name", rec.Name, "trace", rec.TraceID)
+			continue
+		}
+		switch rec.Phase {
+		case Start:
+			s := &Span{Name: rec.Name, Start: rec.Time, Attributes: rec.Attributes}
+			open[rec.Name] = append(open[rec.Name], s)
+			spans = append(spans, s)
+		case End:
+			stack := open[rec.Name]
+			if len(stack) == 0 {
+				slog.Warn("step ended without starting", "name", rec.Name)
+				continue
+			}
+			s := stack[len(stack)-1]
+			open[rec.Name] = stack[:len(stack)-1]
+			s.End = rec.Time
+			for k, v := range rec.Attributes {
+				if s.Attributes == nil {
+					s.Attributes = map[string]string{}
+				}
+				s.Attributes[k] = v
+			}
+		default:
+			return nil, fmt.Errorf("unknown phase %q for step %q", rec.Phase, rec.Name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nest(spans), nil
+}
+
+func nest(spans []*Span) []*Span {
+	sort.SliceStable(spans, func(i, j int) bool {
+		if !spans[i].Start.Equal(spans[j].Start) {
+			return spans[i].Start.Before(spans[j].Start)
+		}
+		// Longer spans first, so they become the parent.
+		return contains(spans[i], spans[j])
+	})
+	roots := []*Span{}
+	stack := []*Span{}
+	for _, s := range spans {
+		for len(stack) > 0 && !contains(stack[len(stack)-1], s) {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, s)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, s)
+		}
+		stack = append(stack, s)
+	}
+	return roots
+}
+
+// contains returns true if child lies within parent. Unfinished spans are treated as never ending.
+func contains(parent, child *Span) bool {
+	if child.Start.Before(parent.Start) {
+		return false
+	}
+	if parent.End.IsZero() {
+		return true
+	}
+	if child.End.IsZero() {
+		return false
+	}
+	return !child.End.After(parent.End)
+}
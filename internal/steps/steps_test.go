@@ -0,0 +1,164 @@
+package steps
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const traceID = "0b8a4d4e1b3c11ee9a265a3f0b7d0c41"
+
+var epoch = time.Date(2023, time.July, 1, 0, 0, 0, 0, time.UTC)
+
+// record returns a line of a steps file, for a step at sec seconds into the job.
+func record(name string, phase Phase, sec int) string {
+	return fmt.Sprintf(`{"traceId":%q,"name":%q,"phase":%q,"time":%q}`, traceID, name, phase, epoch.Add(time.Duration(sec)*time.Second).Format(time.RFC3339Nano))
+}
+
+// tree renders spans as name[start-end], with children in parentheses. Unfinished spans end in "?".
+func tree(spans []*Span) string {
+	var parts []string
+	for _, s := range spans {
+		end := "?"
+		if !s.End.IsZero() {
+			end = fmt.Sprint(s.End.Sub(epoch).Seconds())
+		}
+		p := fmt.Sprintf("%s[%v-%s]", s.Name, s.Start.Sub(epoch).Seconds(), end)
+		if len(s.Children) > 0 {
+			p += "(" + tree(s.Children) + ")"
+		}
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestRead(t *testing.T) {
+	cases := []struct {
+		name  string
+		lines []string
+		want  string
+		err   string
+	}{
+		{
+			name: "nested",
+			lines: []string{
+				record("test", Start, 0),
+				record("test/unit", Start, 1),
+				record("test/unit", End, 5),
+				record("test", End, 10),
+			},
+			want: "test[0-10](test/unit[1-5])",
+		},
+		{
+			name: "overlapping",
+			lines: []string{
+				record("build", Start, 0),
+				record("lint", Start, 5),
+				record("build", End, 10),
+				record("lint", End, 15),
+			},
+			want: "build[0-10] lint[5-15]",
+		},
+		{
+			name: "back to back",
+			lines: []string{
+				record("build", Start, 0),
+				record("build", End, 10),
+				record("test", Start, 10),
+				record("test", End, 20),
+			},
+			want: "build[0-10] test[10-20]",
+		},
+		{
+			name: "same start",
+			lines: []string{
+				record("inner", Start, 0),
+				record("outer", Start, 0),
+				record("inner", End, 5),
+				record("outer", End, 10),
+			},
+			want: "outer[0-10](inner[0-5])",
+		},
+		{
+			name: "never ended",
+			lines: []string{
+				record("test", Start, 0),
+				record("test/unit", Start, 1),
+				record("test/unit", End, 5),
+				record("test/e2e", Start, 6),
+			},
+			want: "test[0-?](test/unit[1-5] test/e2e[6-?])",
+		},
+		{
+			name: "repeated name",
+			lines: []string{
+				record("retry", Start, 0),
+				record("retry", End, 5),
+				record("retry", Start, 6),
+				record("retry", End, 8),
+			},
+			want: "retry[0-5] retry[6-8]",
+		},
+		{
+			name: "ended without starting",
+			lines: []string{
+				record("test", End, 5),
+				record("build", Start, 6),
+				record("build", End, 8),
+			},
+			want: "build[6-8]",
+		},
+		{
+			name: "other trace and blank lines",
+			lines: []string{
+				strings.Replace(record("other", Start, 0), traceID, "ffffffffffffffffffffffffffffffff", 1),
+				"",
+				record("test", Start, 1),
+				record("test", End, 2),
+			},
+			want: "test[1-2]",
+		},
+		{
+			name:  "malformed",
+			lines: []string{record("test", Start, 0), `{"traceId":`},
+			err:   "unexpected end of JSON input",
+		},
+		{
+			name:  "unknown phase",
+			lines: []string{strings.Replace(record("test", Start, 0), `"start"`, `"middle"`, 1)},
+			err:   `unknown phase "middle"`,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			spans, err := Read(strings.NewReader(strings.Join(tt.lines, "\n")), traceID)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tree(spans); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadAttributes(t *testing.T) {
+	lines := []string{
+		`{"traceId":"` + traceID + `","name":"test","phase":"start","time":"2023-07-01T00:00:00Z","attributes":{"target":"./..."}}`,
+		`{"traceId":"` + traceID + `","name":"test","phase":"end","time":"2023-07-01T00:00:10Z","attributes":{"result":"failed"}}`,
+	}
+	spans, err := Read(strings.NewReader(strings.Join(lines, "\n")), traceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(spans[0].Attributes); got != "map[result:failed target:./...]" {
+		t.Fatalf("got attributes %v, want those from the start and end merged", got)
+	}
+}
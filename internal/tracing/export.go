@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/howardjohn/prow-tracing/internal/model"
+	"github.com/howardjohn/prow-tracing/internal/span"
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Export sends a span tree as the trace of pj to exporters, or if none are given, the exporter
+// configured from the environment.
+func Export(pj model.ProwJob, root *span.Span, exporters ...tracesdk.SpanExporter) error {
+	sc, err := ProwJobIdentity(pj).RootSpanContext()
+	if err != nil {
+		return err
+	}
+	c, shutdown, err := NewRoot(pj, exporters...)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+	// Spans recorded inside the job, and links from other traces, refer to the root span by this ID.
+	c.exportAs(root, spanIdentity{id: sc.SpanID()})
+	return nil
+}
+
+func (c Context) export(s *span.Span) {
+	id := spanIdentity{name: s.Name, start: s.Start}
+	if s.ID.IsValid() {
+		id = spanIdentity{id: s.ID}
+	}
+	c.exportAs(s, id)
+}
+
+func (c Context) exportAs(s *span.Span, id spanIdentity) {
+	var child Context
+	if s.Exported {
+		// Already exported, but its children may not have been.
+		child = c.withRemoteParent(s.ID)
+	} else {
+		rec := c.WithLinks(s.Links...).recording(s.Name, s.Start, s.End, id)
+		rec.SetAttributes(s.Attributes...)
+		for _, ev := range s.Events {
+			rec.Event(ev.Name, ev.Time, ev.Attributes...)
+		}
+		if s.Status.Code != codes.Unset {
+			rec.span.SetStatus(s.Status.Code, s.Status.Description)
+		}
+		child = rec.End()
+	}
+	for _, c := range s.Children {
+		child.export(c)
+	}
+}
+
+// withRemoteParent returns a Context for recording children of a span that was recorded elsewhere.
+func (c Context) withRemoteParent(sid trace.SpanID) Context {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.SpanContextFromContext(c.ctx).TraceID(),
+		SpanID:     sid,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	ctx := context.WithValue(trace.ContextWithRemoteSpanContext(c.ctx, sc), spanIDKey{}, nil)
+	return Context{tracer: c.tracer, ctx: ctx}
+}
@@ -0,0 +1,147 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/otlpjson"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+type request struct {
+	path        string
+	contentType string
+	header      http.Header
+	body        []byte
+}
+
+// receiver starts a server recording all requests made to it.
+func receiver(t *testing.T) (string, func() []request) {
+	var mu sync.Mutex
+	reqs := []request{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		reqs = append(reqs, request{path: r.URL.Path, contentType: r.Header.Get("Content-Type"), header: r.Header, body: body})
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request{}, reqs...)
+	}
+}
+
+func TestExporters(t *testing.T) {
+	cases := []struct {
+		exporter    string
+		endpointEnv string
+		path        string
+		contentType string
+	}{
+		{"zipkin", "OTEL_EXPORTER_ZIPKIN_ENDPOINT", "/api/v2/spans", "application/json"},
+		{"jaeger", "OTEL_EXPORTER_JAEGER_ENDPOINT", "/api/traces", "application/x-thrift"},
+		{"otlp", "OTEL_EXPORTER_OTLP_ENDPOINT", "/v1/traces", "application/json"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.exporter, func(t *testing.T) {
+			url, requests := receiver(t)
+			t.Setenv("OTEL_TRACES_EXPORTER", tt.exporter)
+			t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "http/json")
+			endpoint := url
+			if tt.endpointEnv != "OTEL_EXPORTER_OTLP_ENDPOINT" {
+				endpoint += tt.path
+			}
+			t.Setenv(tt.endpointEnv, endpoint)
+
+			exp, err := Exporter()
+			if err != nil {
+				t.Fatal(err)
+			}
+			start := time.Date(2023, time.July, 1, 0, 0, 0, 0, time.UTC)
+			span := tracetest.SpanStub{
+				Name: "init/clonerefs",
+				SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID:    trace.TraceID{0x0b, 0x8a, 0x4d, 0x4e, 0x1b, 0x3c, 0x11, 0xee, 0x9a, 0x26, 0x5a, 0x3f, 0x0b, 0x7d, 0x0c, 0x41},
+					SpanID:     trace.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+					TraceFlags: trace.FlagsSampled,
+				}),
+				StartTime: start,
+				EndTime:   start.Add(time.Minute),
+				Resource:  resource.Default(),
+			}
+			if err := exp.ExportSpans(context.Background(), tracetest.SpanStubs{span}.Snapshots()); err != nil {
+				t.Fatal(err)
+			}
+			if err := exp.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			reqs := requests()
+			if len(reqs) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(reqs))
+			}
+			got := reqs[0]
+			if got.path != tt.path {
+				t.Errorf("path: got %q, want %q", got.path, tt.path)
+			}
+			if got.contentType != tt.contentType {
+				t.Errorf("content type: got %q, want %q", got.contentType, tt.contentType)
+			}
+			if !bytes.Contains(got.body, []byte("init/clonerefs")) {
+				t.Errorf("span name missing from body: %q", got.body)
+			}
+		})
+	}
+}
+
+// TestJSONClient checks that the http/json client sends the OTLP JSON encoding of spans, with the
+// configured headers.
+func TestJSONClient(t *testing.T) {
+	url, requests := receiver(t)
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", url+"/")
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "api-key=se%20cret, x-tenant = ci")
+	c, err := newJSONClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := []*tracepb.ResourceSpans{{ScopeSpans: []*tracepb.ScopeSpans{{Spans: []*tracepb.Span{{
+		TraceId: []byte{0x0b, 0x8a, 0x4d, 0x4e, 0x1b, 0x3c, 0x11, 0xee, 0x9a, 0x26, 0x5a, 0x3f, 0x0b, 0x7d, 0x0c, 0x41},
+		SpanId:  []byte{1, 2, 3, 4, 5, 6, 7, 8},
+		Name:    "job",
+	}}}}}}
+	if err := c.UploadTraces(context.Background(), spans); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := requests()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(reqs))
+	}
+	got := reqs[0]
+	if got.path != "/v1/traces" {
+		t.Errorf("path: got %q, want /v1/traces", got.path)
+	}
+	if got.header.Get("api-key") != "se cret" || got.header.Get("x-tenant") != "ci" {
+		t.Errorf("headers not sent: %v", got.header)
+	}
+	want, err := otlpjson.Marshal(&coltracepb.ExportTraceServiceRequest{ResourceSpans: spans})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.body, want) {
+		t.Errorf("body: got %s, want %s", got.body, want)
+	}
+}
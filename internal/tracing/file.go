@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/howardjohn/prow-tracing/internal/otlpjson"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// writerClient is an otlptrace.Client writing spans as OTLP JSON, one ExportTraceServiceRequest per
+// line. This allows traces to be produced without a collector, for instance to be archived as job
+// artifacts and replayed later.
+type writerClient struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+var _ otlptrace.Client = &writerClient{}
+
+func newWriterClient(w io.WriteCloser) *writerClient {
+	return &writerClient{w: w}
+}
+
+func (c *writerClient) Start(ctx context.Context) error {
+	return nil
+}
+
+func (c *writerClient) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.w.Close()
+}
+
+func (c *writerClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	b, err := otlpjson.Marshal(&coltracepb.ExportTraceServiceRequest{ResourceSpans: protoSpans})
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.w.Write(append(b, '\n'))
+	return err
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// noopExporter drops all spans.
+type noopExporter struct{}
+
+var _ tracesdk.SpanExporter = noopExporter{}
+
+func (noopExporter) ExportSpans(ctx context.Context, spans []tracesdk.ReadOnlySpan) error {
+	return nil
+}
+
+func (noopExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/otlpjson"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// jsonClient is an otlptrace.Client sending spans with the OTLP http/json protocol, which the
+// upstream otlptracehttp client does not support.
+type jsonClient struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+var _ otlptrace.Client = &jsonClient{}
+
+// newJSONClient returns a jsonClient configured from the standard OTEL_EXPORTER_OTLP_* environment
+// variables.
+func newJSONClient() (*jsonClient, error) {
+	endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if endpoint == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			base = "http://localhost:4318"
+		}
+		endpoint = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	rawHeaders := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_HEADERS")
+	if rawHeaders == "" {
+		rawHeaders = os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")
+	}
+	headers, err := parseHeaders(rawHeaders)
+	if err != nil {
+		return nil, err
+	}
+	timeout := 10 * time.Second
+	rawTimeout := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_TIMEOUT")
+	if rawTimeout == "" {
+		rawTimeout = os.Getenv("OTEL_EXPORTER_OTLP_TIMEOUT")
+	}
+	if rawTimeout != "" {
+		ms, err := strconv.Atoi(rawTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTLP timeout %q: %v", rawTimeout, err)
+		}
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	return &jsonClient{
+		endpoint: endpoint,
+		headers:  headers,
+		client:   &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// parseHeaders parses headers in the W3C baggage format used by OTEL_EXPORTER_OTLP_HEADERS.
+func parseHeaders(s string) (map[string]string, error) {
+	res := map[string]string{}
+	for _, kv := range strings.Split(s, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid OTLP header %q", kv)
+		}
+		v, err := url.QueryUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid OTLP header %q: %v", kv, err)
+		}
+		res[strings.TrimSpace(k)] = v
+	}
+	return res, nil
+}
+
+func (c *jsonClient) Start(ctx context.Context) error {
+	return nil
+}
+
+func (c *jsonClient) Stop(ctx context.Context) error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+func (c *jsonClient) UploadTraces(ctx context.Context, protoSpans []*tracepb.ResourceSpans) error {
+	body, err := otlpjson.Marshal(&coltracepb.ExportTraceServiceRequest{ResourceSpans: protoSpans})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("export to %v failed with status %v: %s", c.endpoint, resp.Status, msg)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package tracing
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/howardjohn/prow-tracing/internal/model"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+)
+
+// JobIdentity holds the values identifying a prow job that its trace ID is derived from.
+type JobIdentity struct {
+	// ID is the prow job ID, from the prow.k8s.io/id label or $PROW_JOB_ID. This is normally a UUID.
+	ID string
+	// BuildID is the build ID, from the prow.k8s.io/build-id label or $BUILD_ID.
+	BuildID string
+	// Job is the job name, from the prow.k8s.io/job label or $JOB_NAME.
+	Job string
+	// UID is the UID of the ProwJob object. This is not available from inside the job.
+	UID string
+	// Upstream is the trace context that triggered the job, if any.
+	Upstream trace.SpanContext
+}
+
+// ProwJobIdentity returns the identity of a ProwJob.
+func ProwJobIdentity(pj model.ProwJob) JobIdentity {
+	id := pj.Labels["prow.k8s.io/id"]
+	if id == "" {
+		// The ProwJob is named by its ID, so this is equivalent.
+		id = pj.Name
+	}
+	return JobIdentity{
+		ID:       id,
+		BuildID:  pj.Labels["prow.k8s.io/build-id"],
+		Job:      pj.Labels["prow.k8s.io/job"],
+		UID:      string(pj.UID),
+		Upstream: upstreamContext(pj),
+	}
+}
+
+// EnvIdentity returns the identity of the prow job we are running inside of, from the environment
+// variables Prow sets on the job's containers.
+func EnvIdentity() JobIdentity {
+	return JobIdentity{
+		ID:       os.Getenv("PROW_JOB_ID"),
+		BuildID:  os.Getenv("BUILD_ID"),
+		Job:      os.Getenv("JOB_NAME"),
+		Upstream: envUpstreamContext(),
+	}
+}
+
+// TraceID returns the trace ID for the job. When the prow job ID is a UUID, the trace ID is the UUID
+// itself. Otherwise, it is derived from a hash of the build ID and job name, which are available both
+// inside and outside the job. As a last resort, the UID of the ProwJob object is used.
+func (j JobIdentity) TraceID() (trace.TraceID, error) {
+	u, idErr := Parse(j.ID)
+	if idErr == nil {
+		return trace.TraceID(u), nil
+	}
+	if j.BuildID != "" && j.Job != "" {
+		slog.Warn("prow job ID is not a UUID, deriving trace ID from build", "id", j.ID, "build", j.BuildID, "job", j.Job)
+		h := sha256.Sum256([]byte(j.Job + "\x00" + j.BuildID))
+		return trace.TraceID(h[0:16]), nil
+	}
+	if u, err := Parse(j.UID); err == nil {
+		slog.Warn("prow job ID is not a UUID, deriving trace ID from UID", "id", j.ID, "uid", j.UID)
+		return trace.TraceID(u), nil
+	}
+	if j.ID == "" {
+		idErr = errors.New("missing prow job ID")
+	}
+	return trace.TraceID{}, fmt.Errorf("cannot derive trace ID: %v, and build ID or job name are not set", idErr)
+}
+
+// RootSpanContext returns the span context of the job's root span, which spans recorded inside the job
+// are parented under and other traces link to. Its span ID is derived from the job's trace ID, but in
+// UpstreamParent mode it is part of the upstream trace.
+func (j JobIdentity) RootSpanContext() (trace.SpanContext, error) {
+	tid, err := j.TraceID()
+	if err != nil {
+		return trace.SpanContext{}, err
+	}
+	sid := rootSpanID(tid)
+	if j.Upstream.IsValid() {
+		mode, err := upstreamMode()
+		if err != nil {
+			return trace.SpanContext{}, err
+		}
+		if mode == UpstreamParent {
+			tid = j.Upstream.TraceID()
+		}
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    tid,
+		SpanID:     sid,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	}), nil
+}
@@ -0,0 +1,103 @@
+package tracing
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRootSpanContext(t *testing.T) {
+	uuid := trace.TraceID{0x9a, 0x3b, 0x7c, 0x1e, 0x4f, 0x2d, 0x11, 0xee, 0x8c, 0x99, 0x02, 0x42, 0xac, 0x12, 0x00, 0x02}
+	uid := trace.TraceID{0x2f, 0x6e, 0x1d, 0x5c, 0x4b, 0x3a, 0x49, 0x28, 0x97, 0x86, 0x75, 0x64, 0x53, 0x42, 0x31, 0x20}
+	build := sha256.Sum256([]byte("unit-tests_istio\x001680000000000000001"))
+	upstream := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0xaa, 0xbb},
+		SpanID:     trace.SpanID{0xcc},
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+	cases := []struct {
+		name    string
+		job     JobIdentity
+		mode    string
+		traceID trace.TraceID
+		rootTID trace.TraceID
+		err     string
+	}{
+		{
+			name:    "uuid",
+			job:     JobIdentity{ID: "9a3b7c1e-4f2d-11ee-8c99-0242ac120002", BuildID: "1680000000000000001", Job: "unit-tests_istio", UID: "2f6e1d5c-4b3a-4928-9786-756453423120"},
+			traceID: uuid,
+		},
+		{
+			name:    "build",
+			job:     JobIdentity{ID: "unit-tests-x7k2p", BuildID: "1680000000000000001", Job: "unit-tests_istio", UID: "2f6e1d5c-4b3a-4928-9786-756453423120"},
+			traceID: trace.TraceID(build[0:16]),
+		},
+		{
+			name:    "uid",
+			job:     JobIdentity{ID: "unit-tests-x7k2p", Job: "unit-tests_istio", UID: "2f6e1d5c-4b3a-4928-9786-756453423120"},
+			traceID: uid,
+		},
+		{
+			name: "nothing set",
+			job:  JobIdentity{},
+			err:  "missing prow job ID",
+		},
+		{
+			name: "id not a uuid",
+			job:  JobIdentity{ID: "unit-tests-x7k2p", Job: "unit-tests_istio"},
+			err:  "cannot derive trace ID",
+		},
+		{
+			name:    "upstream link",
+			job:     JobIdentity{ID: "9a3b7c1e-4f2d-11ee-8c99-0242ac120002", Upstream: upstream},
+			traceID: uuid,
+		},
+		{
+			name:    "upstream parent",
+			job:     JobIdentity{ID: "9a3b7c1e-4f2d-11ee-8c99-0242ac120002", Upstream: upstream},
+			mode:    "parent",
+			traceID: uuid,
+			rootTID: upstream.TraceID(),
+		},
+		{
+			name: "unknown upstream mode",
+			job:  JobIdentity{ID: "9a3b7c1e-4f2d-11ee-8c99-0242ac120002", Upstream: upstream},
+			mode: "child",
+			err:  `unsupported upstream mode "child"`,
+		},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PROW_TRACING_UPSTREAM", tt.mode)
+			sc, err := tt.job.RootSpanContext()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tid, err := tt.job.TraceID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tid != tt.traceID {
+				t.Errorf("got trace ID %v, want %v", tid, tt.traceID)
+			}
+			// The root span keeps an ID derived from the job, even when it joins the upstream trace.
+			rootTID := tt.rootTID
+			if !rootTID.IsValid() {
+				rootTID = tt.traceID
+			}
+			if sc.TraceID() != rootTID || sc.SpanID() != rootSpanID(tt.traceID) || !sc.IsSampled() || !sc.IsRemote() {
+				t.Errorf("got root span context %v/%v, want %v/%v", sc.TraceID(), sc.SpanID(), rootTID, rootSpanID(tt.traceID))
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+package tracing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/otlpjson"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Exporter returns the span exporter configured from the environment, as used by NewRoot.
+func Exporter() (tracesdk.SpanExporter, error) {
+	return newExporter()
+}
+
+// ReadTraceFile reads spans from a saved trace file. Both OTLP JSON, with one ExportTraceServiceRequest
+// per line as written by the file exporter, and a single binary protobuf ExportTraceServiceRequest or
+// TracesData are supported.
+func ReadTraceFile(r io.Reader) ([]tracesdk.ReadOnlySpan, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	reqs := []*coltracepb.ExportTraceServiceRequest{}
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '{' {
+		scanner := bufio.NewScanner(bytes.NewReader(b))
+		scanner.Buffer(nil, len(b)+1)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			req := &coltracepb.ExportTraceServiceRequest{}
+			if err := otlpjson.Unmarshal(line, req); err != nil {
+				return nil, err
+			}
+			reqs = append(reqs, req)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	} else {
+		req := &coltracepb.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(b, req); err != nil {
+			return nil, fmt.Errorf("trace file is neither OTLP JSON nor protobuf: %v", err)
+		}
+		reqs = append(reqs, req)
+	}
+
+	res := []tracesdk.ReadOnlySpan{}
+	for _, req := range reqs {
+		spans, err := fromResourceSpans(req.ResourceSpans)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, spans...)
+	}
+	return res, nil
+}
+
+// exportBatchSize is the most spans sent to an exporter at once. This matches the SDK's batch span
+// processor, keeping large traces within the message size limits of collectors, such as the 4MB
+// default of OTLP/gRPC.
+const exportBatchSize = 512
+
+// ExportSpans sends spans to the exporter configured from the environment.
+func ExportSpans(spans []tracesdk.ReadOnlySpan) error {
+	exp, err := Exporter()
+	if err != nil {
+		return err
+	}
+	if err := exportBatches(exp, spans); err != nil {
+		_ = exp.Shutdown(context.Background())
+		return err
+	}
+	return exp.Shutdown(context.Background())
+}
+
+// exportBatches sends spans to exp in batches of at most exportBatchSize.
+func exportBatches(exp tracesdk.SpanExporter, spans []tracesdk.ReadOnlySpan) error {
+	for len(spans) > 0 {
+		n := exportBatchSize
+		if len(spans) < n {
+			n = len(spans)
+		}
+		if err := exp.ExportSpans(context.Background(), spans[:n]); err != nil {
+			return err
+		}
+		spans = spans[n:]
+	}
+	return nil
+}
+
+func fromResourceSpans(rss []*tracepb.ResourceSpans) ([]tracesdk.ReadOnlySpan, error) {
+	stubs := tracetest.SpanStubs{}
+	for _, rs := range rss {
+		res := resource.NewWithAttributes(rs.GetSchemaUrl(), fromKeyValues(rs.GetResource().GetAttributes())...)
+		for _, ss := range rs.ScopeSpans {
+			scope := instrumentation.Library{
+				Name:      ss.GetScope().GetName(),
+				Version:   ss.GetScope().GetVersion(),
+				SchemaURL: ss.GetSchemaUrl(),
+			}
+			for _, s := range ss.Spans {
+				stub, err := fromSpan(s)
+				if err != nil {
+					return nil, err
+				}
+				stub.Resource = res
+				stub.InstrumentationLibrary = scope
+				stubs = append(stubs, stub)
+			}
+		}
+	}
+	return stubs.Snapshots(), nil
+}
+
+func fromSpan(s *tracepb.Span) (tracetest.SpanStub, error) {
+	sc, err := spanContext(s.TraceId, s.SpanId, s.TraceState)
+	if err != nil {
+		return tracetest.SpanStub{}, fmt.Errorf("span %q: %v", s.Name, err)
+	}
+	stub := tracetest.SpanStub{
+		Name:              s.Name,
+		SpanContext:       sc,
+		SpanKind:          trace.SpanKind(s.Kind),
+		StartTime:         time.Unix(0, int64(s.StartTimeUnixNano)),
+		EndTime:           time.Unix(0, int64(s.EndTimeUnixNano)),
+		Attributes:        fromKeyValues(s.Attributes),
+		DroppedAttributes: int(s.DroppedAttributesCount),
+		DroppedEvents:     int(s.DroppedEventsCount),
+		DroppedLinks:      int(s.DroppedLinksCount),
+	}
+	if len(s.ParentSpanId) > 0 {
+		stub.Parent, err = spanContext(s.TraceId, s.ParentSpanId, "")
+		if err != nil {
+			return tracetest.SpanStub{}, fmt.Errorf("span %q parent: %v", s.Name, err)
+		}
+	}
+	for _, ev := range s.Events {
+		stub.Events = append(stub.Events, tracesdk.Event{
+			Name:                  ev.Name,
+			Time:                  time.Unix(0, int64(ev.TimeUnixNano)),
+			Attributes:            fromKeyValues(ev.Attributes),
+			DroppedAttributeCount: int(ev.DroppedAttributesCount),
+		})
+	}
+	for _, l := range s.Links {
+		lsc, err := spanContext(l.TraceId, l.SpanId, l.TraceState)
+		if err != nil {
+			return tracetest.SpanStub{}, fmt.Errorf("span %q link: %v", s.Name, err)
+		}
+		stub.Links = append(stub.Links, tracesdk.Link{
+			SpanContext:           lsc,
+			Attributes:            fromKeyValues(l.Attributes),
+			DroppedAttributeCount: int(l.DroppedAttributesCount),
+		})
+	}
+	switch s.GetStatus().GetCode() {
+	case tracepb.Status_STATUS_CODE_OK:
+		stub.Status = tracesdk.Status{Code: codes.Ok}
+	case tracepb.Status_STATUS_CODE_ERROR:
+		stub.Status = tracesdk.Status{Code: codes.Error, Description: s.GetStatus().GetMessage()}
+	}
+	return stub, nil
+}
+
+func spanContext(tid, sid []byte, state string) (trace.SpanContext, error) {
+	if len(tid) != 16 || len(sid) != 8 {
+		return trace.SpanContext{}, fmt.Errorf("invalid IDs %x/%x", tid, sid)
+	}
+	ts, err := trace.ParseTraceState(state)
+	if err != nil {
+		return trace.SpanContext{}, err
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID(tid),
+		SpanID:     trace.SpanID(sid),
+		TraceFlags: trace.FlagsSampled,
+		TraceState: ts,
+	}), nil
+}
+
+func fromKeyValues(kvs []*commonpb.KeyValue) []attribute.KeyValue {
+	res := make([]attribute.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		res = append(res, attribute.KeyValue{Key: attribute.Key(kv.Key), Value: fromAnyValue(kv.Value)})
+	}
+	return res
+}
+
+// fromAnyValue converts an OTLP value to an attribute value. OTLP values are richer than attributes;
+// heterogeneous arrays, maps and bytes are converted to strings.
+func fromAnyValue(v *commonpb.AnyValue) attribute.Value {
+	switch v := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return attribute.StringValue(v.StringValue)
+	case *commonpb.AnyValue_BoolValue:
+		return attribute.BoolValue(v.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return attribute.Int64Value(v.IntValue)
+	case *commonpb.AnyValue_DoubleValue:
+		return attribute.Float64Value(v.DoubleValue)
+	case *commonpb.AnyValue_BytesValue:
+		return attribute.StringValue(hex.EncodeToString(v.BytesValue))
+	case *commonpb.AnyValue_ArrayValue:
+		return fromArrayValue(v.ArrayValue.GetValues())
+	case *commonpb.AnyValue_KvlistValue:
+		return attribute.StringValue(v.KvlistValue.String())
+	}
+	return attribute.StringValue("")
+}
+
+func fromArrayValue(vs []*commonpb.AnyValue) attribute.Value {
+	if len(vs) == 0 {
+		return attribute.StringSliceValue(nil)
+	}
+	switch vs[0].GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		if r, ok := homogeneous(vs, (*commonpb.AnyValue).GetStringValue); ok {
+			return attribute.StringSliceValue(r)
+		}
+	case *commonpb.AnyValue_BoolValue:
+		if r, ok := homogeneous(vs, (*commonpb.AnyValue).GetBoolValue); ok {
+			return attribute.BoolSliceValue(r)
+		}
+	case *commonpb.AnyValue_IntValue:
+		if r, ok := homogeneous(vs, (*commonpb.AnyValue).GetIntValue); ok {
+			return attribute.Int64SliceValue(r)
+		}
+	case *commonpb.AnyValue_DoubleValue:
+		if r, ok := homogeneous(vs, (*commonpb.AnyValue).GetDoubleValue); ok {
+			return attribute.Float64SliceValue(r)
+		}
+	}
+	strs := make([]string, 0, len(vs))
+	for _, v := range vs {
+		strs = append(strs, fromAnyValue(v).Emit())
+	}
+	return attribute.StringSliceValue(strs)
+}
+
+// homogeneous returns the values of vs if they are all of the same type as the first.
+func homogeneous[T any](vs []*commonpb.AnyValue, get func(*commonpb.AnyValue) T) ([]T, bool) {
+	res := make([]T, 0, len(vs))
+	first := fmt.Sprintf("%T", vs[0].GetValue())
+	for _, v := range vs {
+		if fmt.Sprintf("%T", v.GetValue()) != first {
+			return nil, false
+		}
+		res = append(res, get(v))
+	}
+	return res, true
+}
@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestReadTraceFile checks that spans written by the file exporter are read back unchanged.
+func TestReadTraceFile(t *testing.T) {
+	tid := trace.TraceID{0x0b, 0x8a, 0x4d, 0x4e, 0x1b, 0x3c, 0x11, 0xee, 0x9a, 0x26, 0x5a, 0x3f, 0x0b, 0x7d, 0x0c, 0x41}
+	sc := func(sid byte) trace.SpanContext {
+		return trace.NewSpanContext(trace.SpanContextConfig{TraceID: tid, SpanID: trace.SpanID{sid}, TraceFlags: trace.FlagsSampled})
+	}
+	start := time.Date(2023, time.July, 1, 0, 0, 0, 123456789, time.UTC)
+	attrs := []attribute.KeyValue{
+		attribute.String("k8s.container.name", "test"),
+		attribute.Int64("container.exit_code", 137),
+		attribute.Float64("ratio", 0.25),
+		attribute.Bool("incomplete", true),
+		attribute.StringSlice("args", []string{"go", "test"}),
+	}
+	want := tracetest.SpanStubs{
+		{Name: "job", SpanContext: sc(1), StartTime: start, EndTime: start.Add(time.Hour + time.Nanosecond), Resource: resource.Empty()},
+		{
+			Name:        "container/test",
+			SpanContext: sc(2),
+			Parent:      sc(1),
+			StartTime:   start.Add(time.Second),
+			EndTime:     start.Add(time.Minute),
+			Attributes:  attrs,
+			Status:      tracesdk.Status{Code: codes.Error, Description: "exit code 137"},
+			Resource:    resource.Empty(),
+		},
+	}
+
+	var buf bytes.Buffer
+	exp, err := otlptrace.New(context.Background(), newWriterClient(nopCloser{&buf}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.ExportSpans(context.Background(), want.Snapshots()); err != nil {
+		t.Fatal(err)
+	}
+	spans, err := ReadTraceFile(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != len(want) {
+		t.Fatalf("got %d spans, want %d", len(spans), len(want))
+	}
+	for i, got := range spans {
+		w := want[i]
+		if !sameSpan(got.SpanContext(), w.SpanContext) || !sameSpan(got.Parent(), w.Parent) {
+			t.Errorf("%v: got IDs %v/%v parent %v, want %v/%v parent %v", w.Name,
+				got.SpanContext().TraceID(), got.SpanContext().SpanID(), got.Parent().SpanID(),
+				w.SpanContext.TraceID(), w.SpanContext.SpanID(), w.Parent.SpanID())
+		}
+		if !got.StartTime().Equal(w.StartTime) || !got.EndTime().Equal(w.EndTime) {
+			t.Errorf("%v: got times %v-%v, want %v-%v", w.Name, got.StartTime(), got.EndTime(), w.StartTime, w.EndTime)
+		}
+		if got.Status() != w.Status {
+			t.Errorf("%v: got status %v, want %v", w.Name, got.Status(), w.Status)
+		}
+		gotAttrs := attribute.NewSet(got.Attributes()...)
+		if wantAttrs := attribute.NewSet(w.Attributes...); !gotAttrs.Equals(&wantAttrs) {
+			t.Errorf("%v: got attributes %v, want %v", w.Name, gotAttrs.Encoded(attribute.DefaultEncoder()), wantAttrs.Encoded(attribute.DefaultEncoder()))
+		}
+	}
+}
+
+// TestExportBatches checks that spans are sent in batches small enough for collectors to accept.
+func TestExportBatches(t *testing.T) {
+	stubs := make(tracetest.SpanStubs, 2*exportBatchSize+1)
+	exp := &countingExporter{}
+	if err := exportBatches(exp, stubs.Snapshots()); err != nil {
+		t.Fatal(err)
+	}
+	want := []int{exportBatchSize, exportBatchSize, 1}
+	if len(exp.batches) != len(want) || exp.batches[0] != want[0] || exp.batches[2] != want[2] {
+		t.Fatalf("got batches %v, want %v", exp.batches, want)
+	}
+}
+
+type countingExporter struct {
+	batches []int
+}
+
+func (c *countingExporter) ExportSpans(_ context.Context, spans []tracesdk.ReadOnlySpan) error {
+	c.batches = append(c.batches, len(spans))
+	return nil
+}
+
+func (c *countingExporter) Shutdown(context.Context) error {
+	return nil
+}
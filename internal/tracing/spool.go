@@ -0,0 +1,327 @@
+package tracing
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/span"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SpoolFileName is the name of the file, relative to $ARTIFACTS, that spans recorded inside a job are
+// durably written to. This allows spans to be recovered if the job is killed before they are exported.
+const SpoolFileName = "spans.jsonl"
+
+const (
+	spoolStart    = "start"
+	spoolEnd      = "end"
+	spoolExported = "exported"
+)
+
+// SpoolRecord is a single line in the spool file. A span is written once when it starts, once when it
+// ends, and a final time once it has been exported.
+type SpoolRecord struct {
+	Phase         string           `json:"phase"`
+	TraceID       string           `json:"traceId"`
+	SpanID        string           `json:"spanId"`
+	ParentSpanID  string           `json:"parentSpanId,omitempty"`
+	Name          string           `json:"name,omitempty"`
+	Start         time.Time        `json:"start,omitempty"`
+	End           time.Time        `json:"end,omitempty"`
+	Attributes    []SpoolAttribute `json:"attributes,omitempty"`
+	Events        []SpoolEvent     `json:"events,omitempty"`
+	Error         bool             `json:"error,omitempty"`
+	StatusMessage string           `json:"statusMessage,omitempty"`
+}
+
+type SpoolAttribute struct {
+	Key   string `json:"key"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+type SpoolEvent struct {
+	Name       string           `json:"name"`
+	Time       time.Time        `json:"time"`
+	Attributes []SpoolAttribute `json:"attributes,omitempty"`
+}
+
+// spool writes span records to a file as they start and end.
+type spool struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func newSpool(dir string) (*spool, error) {
+	f, err := os.OpenFile(filepath.Join(dir, SpoolFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &spool{f: f}, nil
+}
+
+func (s *spool) write(recs ...SpoolRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, r := range recs {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if _, err := s.f.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+	// Sync so the records survive the job being killed.
+	return s.f.Sync()
+}
+
+func (s *spool) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// spoolProcessor is a SpanProcessor recording spans to the spool as they start and end.
+type spoolProcessor struct {
+	spool *spool
+}
+
+var _ tracesdk.SpanProcessor = spoolProcessor{}
+
+func (p spoolProcessor) OnStart(parent context.Context, s tracesdk.ReadWriteSpan) {
+	rec := spoolRecord(s)
+	rec.Phase = spoolStart
+	rec.End = time.Time{}
+	rec.Events = nil
+	p.report(p.spool.write(rec))
+}
+
+func (p spoolProcessor) OnEnd(s tracesdk.ReadOnlySpan) {
+	rec := spoolRecord(s)
+	rec.Phase = spoolEnd
+	p.report(p.spool.write(rec))
+}
+
+func (p spoolProcessor) report(err error) {
+	if err != nil {
+		log.Printf("failed to write spool: %v", err)
+	}
+}
+
+func (p spoolProcessor) Shutdown(ctx context.Context) error {
+	return p.spool.close()
+}
+
+func (p spoolProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// spoolExporter wraps an exporter, marking spans in the spool once they are successfully exported.
+type spoolExporter struct {
+	tracesdk.SpanExporter
+	spool *spool
+}
+
+func (e spoolExporter) ExportSpans(ctx context.Context, spans []tracesdk.ReadOnlySpan) error {
+	if err := e.SpanExporter.ExportSpans(ctx, spans); err != nil {
+		return err
+	}
+	recs := make([]SpoolRecord, 0, len(spans))
+	for _, s := range spans {
+		recs = append(recs, SpoolRecord{
+			Phase:   spoolExported,
+			TraceID: s.SpanContext().TraceID().String(),
+			SpanID:  s.SpanContext().SpanID().String(),
+		})
+	}
+	return e.spool.write(recs...)
+}
+
+func spoolRecord(s tracesdk.ReadOnlySpan) SpoolRecord {
+	rec := SpoolRecord{
+		TraceID:       s.SpanContext().TraceID().String(),
+		SpanID:        s.SpanContext().SpanID().String(),
+		Name:          s.Name(),
+		Start:         s.StartTime(),
+		End:           s.EndTime(),
+		Attributes:    toSpoolAttributes(s.Attributes()),
+		Error:         s.Status().Code == codes.Error,
+		StatusMessage: s.Status().Description,
+	}
+	if s.Parent().IsValid() {
+		rec.ParentSpanID = s.Parent().SpanID().String()
+	}
+	for _, ev := range s.Events() {
+		rec.Events = append(rec.Events, SpoolEvent{
+			Name:       ev.Name,
+			Time:       ev.Time,
+			Attributes: toSpoolAttributes(ev.Attributes),
+		})
+	}
+	return rec
+}
+
+// ReadSpool reads a spool file into span trees, returning the spans whose parent is not in the spool.
+// Spans that were already exported live are marked as Exported. Spans that started but never ended,
+// for instance because the job was killed, are ended at end and marked as failed.
+func ReadSpool(r io.Reader, end time.Time) ([]*span.Span, error) {
+	spans := map[string]*SpoolRecord{}
+	order := []string{}
+	exported := map[string]bool{}
+	// Read whole lines rather than using a Scanner, as a span with many attributes has no size limit.
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			if err == io.EOF {
+				break
+			}
+			continue
+		}
+		var rec SpoolRecord
+		dec := json.NewDecoder(bytes.NewReader(line))
+		// Keep numbers exact, as INT64 attributes above 2^53 do not fit in a float64.
+		dec.UseNumber()
+		if err := dec.Decode(&rec); err != nil {
+			return nil, err
+		}
+		key := rec.TraceID + "/" + rec.SpanID
+		switch rec.Phase {
+		case spoolStart, spoolEnd:
+			if _, f := spans[key]; !f {
+				order = append(order, key)
+			}
+			// An end record is a superset of the start record, so the latest record wins.
+			spans[key] = &rec
+		case spoolExported:
+			exported[key] = true
+		default:
+			return nil, fmt.Errorf("unknown phase %q for span %v", rec.Phase, rec.SpanID)
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+
+	nodes := map[string]*span.Span{}
+	for _, key := range order {
+		rec := spans[key]
+		sid, err := trace.SpanIDFromHex(rec.SpanID)
+		if err != nil {
+			return nil, err
+		}
+		s := span.New(rec.Name, rec.Start, rec.End)
+		s.ID = sid
+		s.Exported = exported[key]
+		s.Attributes = fromSpoolAttributes(rec.Attributes)
+		for _, ev := range rec.Events {
+			s.Event(ev.Name, ev.Time, fromSpoolAttributes(ev.Attributes)...)
+		}
+		if rec.Error {
+			s.SetError(rec.StatusMessage)
+		}
+		if rec.Phase == spoolStart {
+			s.End = end
+			s.SetError("span did not end")
+		}
+		nodes[key] = s
+	}
+	roots := []*span.Span{}
+	for _, key := range order {
+		rec := spans[key]
+		if parent, f := nodes[rec.TraceID+"/"+rec.ParentSpanID]; f {
+			parent.Children = append(parent.Children, nodes[key])
+		} else {
+			roots = append(roots, nodes[key])
+		}
+	}
+	return roots, nil
+}
+
+func toSpoolAttributes(attrs []attribute.KeyValue) []SpoolAttribute {
+	res := make([]SpoolAttribute, 0, len(attrs))
+	for _, a := range attrs {
+		res = append(res, SpoolAttribute{Key: string(a.Key), Type: a.Value.Type().String(), Value: a.Value.AsInterface()})
+	}
+	return res
+}
+
+func fromSpoolAttributes(attrs []SpoolAttribute) []attribute.KeyValue {
+	res := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		key := attribute.Key(a.Key)
+		switch a.Type {
+		case "BOOL":
+			v, _ := a.Value.(bool)
+			res = append(res, key.Bool(v))
+		case "INT64":
+			res = append(res, key.Int64(toInt64(a.Value)))
+		case "FLOAT64":
+			res = append(res, key.Float64(toFloat64(a.Value)))
+		case "STRING":
+			v, _ := a.Value.(string)
+			res = append(res, key.String(v))
+		case "BOOLSLICE":
+			res = append(res, key.BoolSlice(fromSlice[bool](a.Value, func(v any) bool { b, _ := v.(bool); return b })))
+		case "INT64SLICE":
+			res = append(res, key.Int64Slice(fromSlice[int64](a.Value, toInt64)))
+		case "FLOAT64SLICE":
+			res = append(res, key.Float64Slice(fromSlice[float64](a.Value, toFloat64)))
+		case "STRINGSLICE":
+			res = append(res, key.StringSlice(fromSlice[string](a.Value, func(v any) string { s, _ := v.(string); return s })))
+		}
+	}
+	return res
+}
+
+func fromSlice[T any](v any, conv func(any) T) []T {
+	l, _ := v.([]any)
+	res := make([]T, 0, len(l))
+	for _, e := range l {
+		res = append(res, conv(e))
+	}
+	return res
+}
+
+// toInt64 converts a number decoded from the spool, which is a json.Number when read by ReadSpool.
+func toInt64(v any) int64 {
+	switch n := v.(type) {
+	case json.Number:
+		i, err := n.Int64()
+		if err != nil {
+			f, _ := n.Float64()
+			return int64(f)
+		}
+		return i
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
+
+func toFloat64(v any) float64 {
+	switch n := v.(type) {
+	case json.Number:
+		f, _ := n.Float64()
+		return f
+	case float64:
+		return n
+	}
+	return 0
+}
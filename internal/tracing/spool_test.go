@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestSpool checks that spans recorded to the spool by a job are read back, including those that were
+// never exported or never ended.
+func TestSpool(t *testing.T) {
+	dir := t.TempDir()
+	sp, err := newSpool(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2023, time.July, 1, 0, 0, 0, 0, time.UTC)
+	tp := tracesdk.NewTracerProvider(
+		tracesdk.WithSpanProcessor(spoolProcessor{sp}),
+		tracesdk.WithSyncer(spoolExporter{tracetest.NewInMemoryExporter(), sp}),
+	)
+	tracer := tp.Tracer("test")
+	ctx, _ := tracer.Start(context.Background(), "job", trace.WithTimestamp(start))
+	_, build := tracer.Start(ctx, "build", trace.WithTimestamp(start.Add(time.Second)))
+	build.SetAttributes(
+		attribute.Int64("large", math.MaxInt64),
+		attribute.Int64Slice("sizes", []int64{1<<53 + 1, -1}),
+		attribute.Float64("ratio", 0.25),
+		attribute.String("long", strings.Repeat("x", 2*1024*1024)),
+	)
+	build.End(trace.WithTimestamp(start.Add(time.Minute)))
+	// The job is killed before its own span ends, so it is never exported either.
+	f, err := os.Open(filepath.Join(dir, SpoolFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	killed := start.Add(time.Hour)
+	roots, err := ReadSpool(f, killed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(roots) != 1 || len(roots[0].Children) != 1 {
+		t.Fatalf("got %d roots, want the job with the build nested", len(roots))
+	}
+	root, child := roots[0], roots[0].Children[0]
+	if root.Exported || !root.End.Equal(killed) || root.Status.Description != "span did not end" {
+		t.Fatalf("got job exported=%v end=%v status=%q, want it ended when the job was killed", root.Exported, root.End, root.Status.Description)
+	}
+	if !child.Exported || !child.End.Equal(start.Add(time.Minute)) || child.Failed() {
+		t.Fatalf("got build exported=%v end=%v failed=%v, want it exported as recorded", child.Exported, child.End, child.Failed())
+	}
+	for _, want := range []attribute.KeyValue{
+		attribute.Int64("large", math.MaxInt64),
+		attribute.Int64Slice("sizes", []int64{1<<53 + 1, -1}),
+		attribute.Float64("ratio", 0.25),
+	} {
+		if got, _ := child.Attribute(want.Key); got.Emit() != want.Value.Emit() {
+			t.Errorf("got %v=%v, want %v", want.Key, got.Emit(), want.Value.Emit())
+		}
+	}
+	if got, _ := child.Attribute("long"); len(got.AsString()) != 2*1024*1024 {
+		t.Errorf("got a %d byte attribute, want it read in full", len(got.AsString()))
+	}
+}
+
+func TestReadSpoolUnknownPhase(t *testing.T) {
+	b, _ := json.Marshal(SpoolRecord{Phase: "middle", TraceID: "0b8a4d4e1b3c11ee9a265a3f0b7d0c41", SpanID: "0102030405060708"})
+	_, err := ReadSpool(strings.NewReader(string(b)+"\n"), time.Now())
+	if err == nil || !strings.Contains(err.Error(), `unknown phase "middle"`) {
+		t.Fatalf("got error %v, want the unknown phase reported", err)
+	}
+}
@@ -3,6 +3,7 @@ package tracing
 import (
 	"context"
 	crand "crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -18,8 +19,10 @@ import (
 	"github.com/howardjohn/prow-tracing/internal/model"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/jaeger"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/zipkin"
 	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
@@ -27,7 +30,52 @@ import (
 	"go.opentelemetry.io/otel/trace"
 )
 
-func exporter() (tracesdk.TracerProviderOption, error) {
+// newExporter returns the exporter selected by OTEL_TRACES_EXPORTER: "otlp" (the default), "console",
+// "file", "zipkin", "jaeger" or "none".
+func newExporter() (tracesdk.SpanExporter, error) {
+	var c otlptrace.Client
+	switch name := os.Getenv("OTEL_TRACES_EXPORTER"); name {
+	case "", "otlp":
+		oc, err := otlpClient()
+		if err != nil {
+			return nil, err
+		}
+		c = oc
+	case "console":
+		c = newWriterClient(nopCloser{os.Stdout})
+		log.Printf("using console")
+	case "file":
+		path := os.Getenv("PROW_TRACING_FILE")
+		if path == "" {
+			path = "traces.jsonl"
+		}
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		c = newWriterClient(f)
+		log.Printf("using file %v", path)
+	case "zipkin":
+		log.Printf("using Zipkin")
+		// Configured by OTEL_EXPORTER_ZIPKIN_ENDPOINT.
+		return zipkin.New("")
+	case "jaeger":
+		log.Printf("using Jaeger")
+		// Configured by OTEL_EXPORTER_JAEGER_ENDPOINT, OTEL_EXPORTER_JAEGER_USER, and OTEL_EXPORTER_JAEGER_PASSWORD.
+		return jaeger.New(jaeger.WithCollectorEndpoint())
+	case "none":
+		return noopExporter{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported traces exporter %v", name)
+	}
+	traceExporter, err := otlptrace.New(context.Background(), c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+	return traceExporter, nil
+}
+
+func otlpClient() (otlptrace.Client, error) {
 	proto := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
 	if proto == "" {
 		proto = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
@@ -36,80 +84,164 @@ func exporter() (tracesdk.TracerProviderOption, error) {
 		proto = "grpc"
 	}
 
-	var c otlptrace.Client
-
 	switch proto {
 	case "grpc":
-		c = otlptracegrpc.NewClient()
 		log.Printf("using gRPC")
+		return otlptracegrpc.NewClient(), nil
 	case "http/protobuf":
-		c = otlptracehttp.NewClient()
 		log.Printf("using HTTP")
-	// case "http/json": // unsupported by library
+		return otlptracehttp.NewClient(), nil
+	case "http/json":
+		log.Printf("using HTTP JSON")
+		return newJSONClient()
 	default:
 		return nil, fmt.Errorf("unsupported otlp protocol %v", proto)
 	}
-	traceExporter, err := otlptrace.New(context.Background(), c)
+}
+
+// liveExporter returns span processors that export each span as soon as it ends, rather than batching
+// until shutdown, so spans are not lost if the job is killed. If $ARTIFACTS is set, spans are also
+// written to a spool file there as they start and end, allowing them to be recovered later.
+func liveExporter() ([]tracesdk.TracerProviderOption, error) {
+	traceExporter, err := newExporter()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+		return nil, err
+	}
+	dir := os.Getenv("ARTIFACTS")
+	if dir == "" {
+		return []tracesdk.TracerProviderOption{
+			tracesdk.WithSpanProcessor(tracesdk.NewSimpleSpanProcessor(traceExporter)),
+		}, nil
+	}
+	s, err := newSpool(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool: %w", err)
 	}
-	return tracesdk.WithSpanProcessor(tracesdk.NewBatchSpanProcessor(traceExporter)), nil
+	// Order matters: the spool must see the span end before it is marked as exported.
+	return []tracesdk.TracerProviderOption{
+		tracesdk.WithSpanProcessor(spoolProcessor{spool: s}),
+		tracesdk.WithSpanProcessor(tracesdk.NewSimpleSpanProcessor(spoolExporter{SpanExporter: traceExporter, spool: s})),
+	}, nil
 }
 
 type idGenerator struct {
 	sync.Mutex
 	randSource *rand.Rand
-	pj         model.ProwJob
+	traceID    trace.TraceID
+	issued     map[trace.SpanID]struct{}
 }
 
 var _ tracesdk.IDGenerator = &idGenerator{}
 
-// NewSpanID returns a non-zero span ID from a randomly-chosen sequence.
+// NewSpanID returns a non-zero span ID. Spans started from a Context get an ID derived from their
+// parent, name and start time, so exporting the same job twice produces identical spans. Otherwise,
+// the ID is randomly chosen.
 func (gen *idGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
 	gen.Lock()
 	defer gen.Unlock()
-	sid := trace.SpanID{}
-	_, _ = gen.randSource.Read(sid[:])
+	id, ok := ctx.Value(spanIDKey{}).(spanIdentity)
+	if !ok {
+		sid := trace.SpanID{}
+		_, _ = gen.randSource.Read(sid[:])
+		return sid
+	}
+	if id.id.IsValid() {
+		return id.id
+	}
+	parent := trace.SpanContextFromContext(ctx).SpanID()
+	sid := SpanID(traceID, parent, id.name, id.start)
+	// Spans with an identical parent, name, and start time would collide. Disambiguate them by the order
+	// they are created in, which is stable across runs.
+	for i := 1; ; i++ {
+		if _, f := gen.issued[sid]; !f {
+			break
+		}
+		sid = SpanID(traceID, parent, fmt.Sprintf("%s#%d", id.name, i), id.start)
+	}
+	gen.issued[sid] = struct{}{}
 	return sid
 }
 
-// NewIDs returns a non-zero trace ID and a non-zero span ID from a
-// randomly-chosen sequence.
+// NewIDs returns a trace ID and span ID for the root span, derived from the prow job.
 func (gen *idGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
-	gen.Lock()
-	defer gen.Unlock()
-	u, _ := Parse(gen.pj.Labels["prow.k8s.io/id"])
-	tid := trace.TraceID(u)
-	sid := trace.SpanID(u[0:8])
-	return tid, sid
+	return gen.traceID, rootSpanID(gen.traceID)
+}
+
+// SpanID returns the deterministic ID of a span within a prow job trace, derived from its parent
+// span, name, and start time.
+func SpanID(tid trace.TraceID, parent trace.SpanID, name string, start time.Time) trace.SpanID {
+	h := sha256.New()
+	h.Write(tid[:])
+	h.Write(parent[:])
+	h.Write([]byte(name))
+	_ = binary.Write(h, binary.BigEndian, start.UnixNano())
+	sum := h.Sum(nil)
+	sid := trace.SpanID(sum[0:8])
+	if !sid.IsValid() {
+		// Astronomically unlikely, but an all zero ID is invalid.
+		sid[7] = 1
+	}
+	return sid
+}
+
+// rootSpanID returns the span ID of the root span of a prow job trace.
+func rootSpanID(tid trace.TraceID) trace.SpanID {
+	return trace.SpanID(tid[0:8])
 }
 
-func newIdGenerator(pj model.ProwJob) tracesdk.IDGenerator {
-	gen := &idGenerator{pj: pj}
+type spanIDKey struct{}
+
+// spanIdentity describes a span about to be started, for the idGenerator to derive its ID from.
+type spanIdentity struct {
+	// id, if set, is used as-is.
+	id    trace.SpanID
+	name  string
+	start time.Time
+}
+
+func newIdGenerator(job JobIdentity) (tracesdk.IDGenerator, error) {
+	tid, err := job.TraceID()
+	if err != nil {
+		return nil, err
+	}
+	gen := &idGenerator{traceID: tid, issued: map[trace.SpanID]struct{}{}}
 	var rngSeed int64
 	_ = binary.Read(crand.Reader, binary.LittleEndian, &rngSeed)
 	gen.randSource = rand.New(rand.NewSource(rngSeed))
-	return gen
+	return gen, nil
 }
 
-func NewAction(uid string) (Context, func(), error) {
+// NewAction returns a Context for recording spans from inside a running job, parented under the
+// root span of the given prow job. If TRACEPARENT is set in the environment, as it is for processes
+// run by `trace exec`, spans are instead parented under that context.
+func NewAction(job JobIdentity) (Context, func(), error) {
 	otel.Tracer("prowjob")
-	exp, err := exporter()
+	exp, err := liveExporter()
 	if err != nil {
 		return Context{}, func() {}, err
 	}
 
-	tp := tracesdk.NewTracerProvider(
+	opts := append([]tracesdk.TracerProviderOption{
 		tracesdk.WithSampler(tracesdk.AlwaysSample()),
-		exp,
 		tracesdk.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName("test"))),
-	)
+	}, exp...)
+	tp := tracesdk.NewTracerProvider(opts...)
 	tracer := tp.Tracer("prowjob-trace")
 	ctx := context.Background()
 	p := propagation.TraceContext{}
-	u, _ := Parse(uid)
-	parent := fmt.Sprintf("%02x-%032x-%016x-%02x", 1, u, u[0:8], 0)
-	ctx = p.Extract(ctx, propagation.MapCarrier{"traceparent": parent})
+	carrier := propagation.MapCarrier{}
+	if tp := os.Getenv("TRACEPARENT"); tp != "" {
+		// We are nested under another traced process; continue its trace.
+		carrier["traceparent"] = tp
+		carrier["tracestate"] = os.Getenv("TRACESTATE")
+		ctx = p.Extract(ctx, carrier)
+	} else {
+		sc, err := job.RootSpanContext()
+		if err != nil {
+			return Context{}, func() {}, err
+		}
+		ctx = trace.ContextWithRemoteSpanContext(ctx, sc)
+	}
 	shutdown := func() {
 		log.Printf("flush %v\n", tp.ForceFlush(context.Background()))
 		log.Printf("shutdown %v\n", tp.Shutdown(context.Background()))
@@ -119,21 +251,42 @@ func NewAction(uid string) (Context, func(), error) {
 	return c, shutdown, nil
 }
 
-func NewRoot(pj model.ProwJob) (Context, func(), error) {
+// NewRoot returns a Context for recording the trace of a prow job. Spans are sent to exporters, or
+// if none are given, the exporter configured from the environment. Given exporters are flushed but
+// not shut down, so they can be reused for other jobs.
+func NewRoot(pj model.ProwJob, exporters ...tracesdk.SpanExporter) (Context, func(), error) {
 	otel.Tracer("prowjob")
-	exp, err := exporter()
+	if len(exporters) == 0 {
+		exp, err := newExporter()
+		if err != nil {
+			return Context{}, func() {}, err
+		}
+		exporters = append(exporters, exp)
+	} else {
+		owned := make([]tracesdk.SpanExporter, 0, len(exporters))
+		for _, exp := range exporters {
+			owned = append(owned, unowned{exp})
+		}
+		exporters = owned
+	}
+
+	id := ProwJobIdentity(pj)
+	ids, err := newIdGenerator(id)
 	if err != nil {
 		return Context{}, func() {}, err
 	}
 
 	attrs := attrFromProwjob(pj)
 	attrs = append(attrs, semconv.ServiceName("prowjob"))
-	tp := tracesdk.NewTracerProvider(
+	opts := []tracesdk.TracerProviderOption{
 		tracesdk.WithSampler(tracesdk.AlwaysSample()),
-		exp,
 		tracesdk.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...)),
-		tracesdk.WithIDGenerator(newIdGenerator(pj)),
-	)
+		tracesdk.WithIDGenerator(ids),
+	}
+	for _, exp := range exporters {
+		opts = append(opts, tracesdk.WithSpanProcessor(tracesdk.NewBatchSpanProcessor(exp)))
+	}
+	tp := tracesdk.NewTracerProvider(opts...)
 	tracer := tp.Tracer("prowjob-trace")
 	ctx := context.Background()
 	shutdown := func() {
@@ -141,10 +294,22 @@ func NewRoot(pj model.ProwJob) (Context, func(), error) {
 		log.Printf("shutdown %v\n", tp.Shutdown(context.Background()))
 	}
 
-	c := Context{tracer: tracer, ctx: ctx}
+	c, err := withUpstream(Context{tracer: tracer, ctx: ctx}, id.Upstream)
+	if err != nil {
+		return Context{}, func() {}, err
+	}
 	return c, shutdown, nil
 }
 
+// unowned wraps an exporter owned by the caller, leaving it open when the tracer provider shuts down.
+type unowned struct {
+	tracesdk.SpanExporter
+}
+
+func (unowned) Shutdown(context.Context) error {
+	return nil
+}
+
 func attrFromProwjob(pj model.ProwJob) []attribute.KeyValue {
 	res := []attribute.KeyValue{}
 	for k, v := range pj.Labels {
@@ -158,6 +323,14 @@ func attrFromProwjob(pj model.ProwJob) []attribute.KeyValue {
 type Context struct {
 	tracer trace.Tracer
 	ctx    context.Context
+	// links are added to the next span recorded from the Context, but not its children.
+	links []trace.Link
+}
+
+// WithLinks returns a Context that adds links to the next span recorded from it.
+func (c Context) WithLinks(links ...trace.Link) Context {
+	c.links = append(append([]trace.Link{}, c.links...), links...)
+	return c
 }
 
 func (c Context) Record(name string, start, end time.Time) Context {
@@ -165,7 +338,14 @@ func (c Context) Record(name string, start, end time.Time) Context {
 }
 
 func (c Context) Recording(name string, start, end time.Time) Recording {
-	ctx, span := c.tracer.Start(c.ctx, name, trace.WithTimestamp(start))
+	return c.recording(name, start, end, spanIdentity{name: name, start: start})
+}
+
+func (c Context) recording(name string, start, end time.Time, id spanIdentity) Recording {
+	ctx := context.WithValue(c.ctx, spanIDKey{}, id)
+	ctx, span := c.tracer.Start(ctx, name, trace.WithTimestamp(start), trace.WithLinks(c.links...))
+	// Children derive their own identity; don't let them inherit ours.
+	ctx = context.WithValue(ctx, spanIDKey{}, nil)
 
 	return Recording{
 		tracer: c.tracer,
@@ -186,6 +366,28 @@ func (c Recording) Event(msg string, t time.Time, attrs ...attribute.KeyValue) {
 	c.span.AddEvent(msg, trace.WithTimestamp(t), trace.WithAttributes(attrs...))
 }
 
+func (c Recording) SetAttributes(attrs ...attribute.KeyValue) {
+	c.span.SetAttributes(attrs...)
+}
+
+// Environ returns the W3C trace context of the recording as environment variables, so child processes
+// can continue the trace.
+func (c Recording) Environ() []string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(c.ctx, carrier)
+	res := []string{}
+	for k, v := range carrier {
+		res = append(res, strings.ToUpper(k)+"="+v)
+	}
+	return res
+}
+
+// EndAt ends the recording at t, for recordings whose end time was not known when they started.
+func (c Recording) EndAt(t time.Time) Context {
+	c.end = t
+	return c.End()
+}
+
 func (c Recording) End() Context {
 	log.Printf("span %v ending", c.span.SpanContext().SpanID())
 	c.span.End(trace.WithTimestamp(c.end))
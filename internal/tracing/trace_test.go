@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestNewSpanID checks that span IDs are derived from the span's parent, name and start time, so that
+// exporting the same job twice produces the same spans.
+func TestNewSpanID(t *testing.T) {
+	tid := trace.TraceID{0x0b, 0x8a, 0x4d, 0x4e, 0x1b, 0x3c, 0x11, 0xee, 0x9a, 0x26, 0x5a, 0x3f, 0x0b, 0x7d, 0x0c, 0x41}
+	parent := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: tid,
+		SpanID:  rootSpanID(tid),
+	}))
+	start := time.Date(2023, time.July, 1, 0, 0, 0, 0, time.UTC)
+	child := func(name string, start time.Time) context.Context {
+		return context.WithValue(parent, spanIDKey{}, spanIdentity{name: name, start: start})
+	}
+	// issue returns the IDs a fresh generator, as used by a single export, gives to each span in turn.
+	issue := func(ctxs ...context.Context) []trace.SpanID {
+		gen := &idGenerator{traceID: tid, issued: map[trace.SpanID]struct{}{}}
+		var res []trace.SpanID
+		for _, ctx := range ctxs {
+			res = append(res, gen.NewSpanID(ctx, tid))
+		}
+		return res
+	}
+
+	first := issue(child("build", start), child("test", start), child("test", start), child("test", start))
+	second := issue(child("build", start), child("test", start), child("test", start), child("test", start))
+	for i := range first {
+		if first[i] != second[i] {
+			t.Errorf("span %d: got %v and %v from two exports, want the same ID", i, first[i], second[i])
+		}
+	}
+	if want := SpanID(tid, rootSpanID(tid), "build", start); first[0] != want {
+		t.Errorf("got %v, want %v derived from the parent, name and start", first[0], want)
+	}
+	seen := map[trace.SpanID]int{}
+	for i, sid := range first {
+		if j, f := seen[sid]; f {
+			t.Errorf("spans %d and %d both got %v, want siblings with the same name and start told apart", j, i, sid)
+		}
+		seen[sid] = i
+	}
+	if want := SpanID(tid, rootSpanID(tid), "test#1", start); first[2] != want {
+		t.Errorf("got %v for the second sibling, want %v", first[2], want)
+	}
+
+	if got := issue(child("build", start.Add(time.Nanosecond)))[0]; got == first[0] {
+		t.Errorf("got %v for a span starting later, want a different ID", got)
+	}
+	if got := issue(context.WithValue(parent, spanIDKey{}, spanIdentity{id: trace.SpanID{1}}))[0]; got != (trace.SpanID{1}) {
+		t.Errorf("got %v, want the given ID used as-is", got)
+	}
+}
@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/howardjohn/prow-tracing/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TraceParentAnnotation is the annotation or label on a ProwJob holding the W3C traceparent of
+	// whatever triggered the job, such as a PR push or a parent pipeline.
+	TraceParentAnnotation = "prow.k8s.io/traceparent"
+	// TraceStateAnnotation optionally holds the W3C tracestate accompanying TraceParentAnnotation.
+	TraceStateAnnotation = "prow.k8s.io/tracestate"
+	// TraceParentEnv is the environment variable holding the upstream traceparent, set in the job's pod
+	// spec. Unlike the annotation, it is visible from inside the job, so spans recorded there join the
+	// same trace as the job in UpstreamParent mode. It is distinct from TRACEPARENT, which only nests
+	// spans recorded inside the job under a process that is itself traced.
+	TraceParentEnv = "PROW_TRACING_UPSTREAM_TRACEPARENT"
+	// TraceStateEnv optionally holds the W3C tracestate accompanying TraceParentEnv.
+	TraceStateEnv = "PROW_TRACING_UPSTREAM_TRACESTATE"
+)
+
+// UpstreamMode controls how a job's trace is connected to an upstream trace context.
+type UpstreamMode string
+
+const (
+	// UpstreamLink adds a span link from the job's root span to the upstream span. The job keeps its
+	// own trace ID, so spans recorded from inside the job still join it.
+	UpstreamLink UpstreamMode = "link"
+	// UpstreamParent parents the job's root span under the upstream span, making the job part of
+	// the upstream trace. Spans recorded inside the job only join it if TraceParentEnv is set, and
+	// $PROW_TRACING_UPSTREAM is set to match, in the job's pod.
+	UpstreamParent UpstreamMode = "parent"
+)
+
+// upstreamMode returns the configured UpstreamMode, from $PROW_TRACING_UPSTREAM.
+func upstreamMode() (UpstreamMode, error) {
+	switch m := UpstreamMode(os.Getenv("PROW_TRACING_UPSTREAM")); m {
+	case "", UpstreamLink:
+		return UpstreamLink, nil
+	case UpstreamParent:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unsupported upstream mode %q", m)
+	}
+}
+
+// upstreamContext returns the trace context that triggered a ProwJob, if any. This is looked up in
+// TraceParentEnv in the environment of the job's containers, as that is also what spans recorded
+// inside the job see, then the ProwJob annotations, then labels.
+func upstreamContext(pj model.ProwJob) trace.SpanContext {
+	carrier := propagation.MapCarrier{}
+	if pj.Spec.PodSpec != nil {
+		for _, c := range pj.Spec.PodSpec.Containers {
+			for _, e := range c.Env {
+				switch e.Name {
+				case TraceParentEnv:
+					carrier["traceparent"] = e.Value
+				case TraceStateEnv:
+					carrier["tracestate"] = e.Value
+				}
+			}
+		}
+	}
+	if carrier["traceparent"] == "" {
+		if tp := pj.Annotations[TraceParentAnnotation]; tp != "" {
+			carrier["traceparent"] = tp
+			carrier["tracestate"] = pj.Annotations[TraceStateAnnotation]
+		} else if tp := pj.Labels[TraceParentAnnotation]; tp != "" {
+			carrier["traceparent"] = tp
+		}
+	}
+	return extractUpstream(carrier)
+}
+
+// envUpstreamContext returns the trace context that triggered the job we are running inside of, if any.
+func envUpstreamContext() trace.SpanContext {
+	return extractUpstream(propagation.MapCarrier{
+		"traceparent": os.Getenv(TraceParentEnv),
+		"tracestate":  os.Getenv(TraceStateEnv),
+	})
+}
+
+func extractUpstream(carrier propagation.MapCarrier) trace.SpanContext {
+	if carrier["traceparent"] == "" {
+		return trace.SpanContext{}
+	}
+	ctx := propagation.TraceContext{}.Extract(context.Background(), carrier)
+	return trace.SpanContextFromContext(ctx)
+}
+
+// withUpstream connects the Context to the job's upstream trace context, if it has one.
+func withUpstream(c Context, sc trace.SpanContext) (Context, error) {
+	if !sc.IsValid() {
+		return c, nil
+	}
+	mode, err := upstreamMode()
+	if err != nil {
+		return c, err
+	}
+	switch mode {
+	case UpstreamParent:
+		c.ctx = trace.ContextWithRemoteSpanContext(c.ctx, sc)
+	case UpstreamLink:
+		c = c.WithLinks(trace.Link{
+			SpanContext: sc,
+			Attributes:  []attribute.KeyValue{attribute.String("link.type", "upstream")},
+		})
+	}
+	return c, nil
+}
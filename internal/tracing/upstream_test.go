@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/model"
+	"github.com/howardjohn/prow-tracing/internal/span"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestUpstreamParent checks that in parent mode, spans recorded inside the job and links to the job
+// all refer to the job span that was exported, in the upstream trace.
+func TestUpstreamParent(t *testing.T) {
+	const (
+		id       = "9a3b7c1e-4f2d-11ee-8c99-0242ac120002"
+		upstream = "00-11111111111111111111111111111111-2222222222222222-01"
+	)
+	t.Setenv("PROW_TRACING_UPSTREAM", "parent")
+	pj := model.ProwJob{}
+	pj.Labels = map[string]string{"prow.k8s.io/id": id}
+	pj.Spec.PodSpec = &model.PodSpec{Containers: []model.Container{{
+		Name: "test",
+		Env:  []model.EnvVar{{Name: TraceParentEnv, Value: upstream}},
+	}}}
+
+	start := time.Date(2023, time.July, 1, 0, 0, 0, 0, time.UTC)
+	root := span.New("job", start, start.Add(time.Hour))
+	root.Child("pod", start, start.Add(time.Hour))
+	exp := tracetest.NewInMemoryExporter()
+	if err := Export(pj, root, exp); err != nil {
+		t.Fatal(err)
+	}
+	exported := map[string]tracesdk.ReadOnlySpan{}
+	for _, s := range exp.GetSpans().Snapshots() {
+		exported[s.Name()] = s
+	}
+	job := exported["job"].SpanContext()
+	if got := job.TraceID().String(); got != "11111111111111111111111111111111" {
+		t.Fatalf("job span is in trace %v, want the upstream trace", got)
+	}
+	if got := exported["job"].Parent().SpanID().String(); got != "2222222222222222" {
+		t.Fatalf("job span has parent %v, want the upstream span", got)
+	}
+	if got := exported["pod"].Parent(); !sameSpan(got, job) {
+		t.Fatalf("pod span has parent %v/%v, want the job span %v/%v", got.TraceID(), got.SpanID(), job.TraceID(), job.SpanID())
+	}
+
+	// Links from retries of the job.
+	sc, err := ProwJobIdentity(pj).RootSpanContext()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameSpan(sc, job) {
+		t.Fatalf("links point at %v/%v, want the job span %v/%v", sc.TraceID(), sc.SpanID(), job.TraceID(), job.SpanID())
+	}
+
+	// Spans recorded from inside the job.
+	file := filepath.Join(t.TempDir(), "traces.jsonl")
+	t.Setenv("OTEL_TRACES_EXPORTER", "file")
+	t.Setenv("PROW_TRACING_FILE", file)
+	t.Setenv("ARTIFACTS", "")
+	t.Setenv("TRACEPARENT", "")
+	t.Setenv("PROW_JOB_ID", id)
+	t.Setenv(TraceParentEnv, upstream)
+	c, shutdown, err := NewAction(EnvIdentity())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Record("step", start, start.Add(time.Minute))
+	shutdown()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	spans, err := ReadTraceFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans recorded inside the job, want 1", len(spans))
+	}
+	if got := spans[0].Parent(); !sameSpan(got, job) {
+		t.Fatalf("step span has parent %v/%v, want the job span %v/%v", got.TraceID(), got.SpanID(), job.TraceID(), job.SpanID())
+	}
+}
+
+func sameSpan(a, b trace.SpanContext) bool {
+	return a.TraceID() == b.TraceID() && a.SpanID() == b.SpanID()
+}
@@ -1,17 +1,26 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/howardjohn/prow-tracing/internal/artifacts"
 	"github.com/howardjohn/prow-tracing/internal/gcs"
+	"github.com/howardjohn/prow-tracing/internal/metrics"
 	"github.com/howardjohn/prow-tracing/internal/model"
+	"github.com/howardjohn/prow-tracing/internal/render"
+	"github.com/howardjohn/prow-tracing/internal/span"
+	"github.com/howardjohn/prow-tracing/internal/steps"
 	"github.com/howardjohn/prow-tracing/internal/tracing"
 	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 	"golang.org/x/exp/slog"
 )
 
@@ -27,80 +36,194 @@ func main() {
 	switch cmd {
 	case "", "prowjob":
 		prowjob(args)
-	case "test":
-		test(args)
+	case "span":
+		spanCmd(args)
+	case "trace":
+		traceCmd(args)
+	case "replay", "import":
+		replay(args)
+	case "report":
+		report(args)
+	case "critical-path":
+		criticalPath(args)
+	case "stats":
+		statsCmd(args)
+	case "compare":
+		compare(args)
+	case "diff":
+		diff(args)
+	case "serve-metrics":
+		serveMetrics(args)
+	case "watch":
+		watch(args)
 	}
 }
 
-func test(args []string) {
-	uid := os.Getenv("PROW_JOB_ID")
-	if uid == "" {
-		fatal(fmt.Errorf("PROW_JOB_ID required"))
-	}
-	root, shutdown, err := tracing.NewAction(uid)
+func prowjob(args []string) {
+	flags := flag.NewFlagSet("prowjob", flag.ExitOnError)
+	chromeTrace := flags.String("chrome-trace", "", "also write the trace to this file in Chrome Trace Event Format")
+	printTree := flags.Bool("print", false, "also print the trace to stdout as a tree")
+	waterfall := flags.Bool("waterfall", false, "with --print, draw a waterfall bar for each span")
+	opts := addBuildFlags(flags)
+	fatal(flags.Parse(args))
+	job, err := fetchJob(openJob(flags.Arg(0)))
 	fatal(err)
-	defer shutdown()
-	t0 := time.Date(2023, time.July, 01, 0, 0, 0, 0, time.UTC)
-	ec := root.Record("setup cluster", t0, t0.Add(time.Minute))
-	ec.Record("pull image", t0.Add(time.Second), t0.Add(time.Second*10))
-	cf := root.Record("test/conformance", t0.Add(time.Minute), t0.Add(time.Minute*12))
-	cf1 := cf.Record("test/conformance/subtest1", t0.Add(time.Minute+time.Second), t0.Add(time.Minute+time.Second*7))
-	cf1.Record("test/conformance/subtest1/apply-config", t0.Add(time.Minute+time.Second*2), t0.Add(time.Minute+time.Second*3))
 
-	cf.Record("test/conformance/subtest2", t0.Add(time.Minute+time.Second*8), t0.Add(time.Minute+time.Second*15))
+	opts.Links = true
+	root := buildJob(job, *opts)
+
+	fatal(tracing.Export(job.prowjob, root))
+	if err := metrics.Export(jobMetrics(job, root)); err != nil {
+		slog.Warn("failed to export metrics", "err", err)
+	}
+	if *chromeTrace != "" {
+		f, err := os.Create(*chromeTrace)
+		fatal(err)
+		defer f.Close()
+		fatal(render.Chrome(f, root))
+	}
+	if *printTree {
+		fatal(render.Text(os.Stdout, root, render.TextOptions{Waterfall: *waterfall}))
+	}
 }
 
-func prowjob(args []string) {
-	job := ""
-	if len(args) > 0 {
-		job = args[1]
+// jobArtifacts holds the artifacts of a job run that its trace is built from.
+type jobArtifacts struct {
+	src      artifacts.Source
+	prowjob  model.ProwJob
+	started  model.Started
+	finished model.Finished
+	// pod is nil if the job has no podinfo.json, such as when it was never reported.
+	pod   *model.PodReport
+	clone []model.Record
+}
+
+// openJob returns the artifacts of a job, given either its GCS path or a local directory holding a copy
+// of them.
+func openJob(job string) artifacts.Source {
+	if fi, err := os.Stat(job); err == nil && fi.IsDir() {
+		return artifacts.Dir(job)
 	}
 	if !strings.HasPrefix(job, "istio-prow/") {
 		log.Fatalf("job must be in format istio-prow/pr-logs/..., got %q", job)
 	}
-	client := gcs.NewClient(job)
+	return gcs.NewClient(job)
+}
 
-	prowjob, err := gcs.Fetch[model.ProwJob](client, "prowjob.json")
-	fatal(err)
+// fetchJob fetches the artifacts of a job run that its trace is built from.
+func fetchJob(src artifacts.Source) (jobArtifacts, error) {
+	prowjob, err := artifacts.Fetch[model.ProwJob](src, "prowjob.json")
+	if err != nil {
+		return jobArtifacts{}, err
+	}
+	start, err := artifacts.Fetch[model.Started](src, "started.json")
+	if err != nil {
+		return jobArtifacts{}, err
+	}
+	finished, err := artifacts.Fetch[model.Finished](src, "finished.json")
+	if err != nil {
+		return jobArtifacts{}, err
+	}
+	var pod *model.PodReport
+	if p, err := artifacts.Fetch[model.PodReport](src, "podinfo.json"); err == nil {
+		pod = &p
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return jobArtifacts{}, err
+	}
+	clone, err := artifacts.Fetch[[]model.Record](src, "clone-records.json")
+	if err != nil {
+		return jobArtifacts{}, err
+	}
+	return jobArtifacts{
+		src:      src,
+		prowjob:  prowjob,
+		started:  start,
+		finished: finished,
+		pod:      pod,
+		clone:    clone,
+	}, nil
+}
 
-	start, err := gcs.Fetch[model.Started](client, "started.json")
-	fatal(err)
+// buildOptions controls how buildJob builds a trace.
+type buildOptions struct {
+	// CorrectSkew moves spans that extend outside their parent back inside it.
+	CorrectSkew bool
+	// Links links the job to its prior attempts, and counts them. Finding them lists and reads every
+	// run of the job, so this is only set when tracing a single run.
+	Links bool
+}
 
-	finished, err := gcs.Fetch[model.Finished](client, "finished.json")
-	fatal(err)
+// addBuildFlags registers the flags controlling how traces are built, shared by every command that
+// builds them.
+func addBuildFlags(flags *flag.FlagSet) *buildOptions {
+	opts := &buildOptions{}
+	flags.BoolVar(&opts.CorrectSkew, "correct-skew", false, "move spans that extend outside their parent, usually due to clock skew, back inside it")
+	return opts
+}
 
-	pod, err := gcs.Fetch[model.PodReport](client, "podinfo.json")
-	fatal(err)
+// buildJob builds the trace of a job from its artifacts.
+func buildJob(job jobArtifacts, opts buildOptions) *span.Span {
+	src, prowjob, pod := job.src, job.prowjob, job.pod
 
-	clone, err := gcs.Fetch[[]model.Record](client, "clone-records.json")
-	fatal(err)
+	root := span.New("job", prowjob.Status.StartTime.Time, prowjob.Status.CompletionTime.Time)
+	root.SetAttributes(attribute.String("prow.k8s.io/job", prowjob.Labels["prow.k8s.io/job"]))
+	if opts.Links {
+		prior := priorAttempts(src, prowjob)
+		root.Links = attemptLinks(prior)
+		root.SetAttributes(attribute.Int("prow.attempt", len(prior)+1))
+	}
 
-	slog.Info("running...")
-	_ = prowjob
-	_ = pod
-	slog.Info("check", "start", fromEpoch(start.Timestamp), "pj", prowjob.CreationTimestamp.Time)
-	slog.Info("check", "fin", fromEpoch(*finished.Timestamp), "pj", prowjob.CreationTimestamp.Time)
+	if pod != nil {
+		addPod(root, job)
+	} else {
+		slog.Warn("job has no podinfo.json, so its pod and containers are missing from the trace")
+		addSteps(root, src, prowjob, root.End)
+		addSpool(root, src, root.End)
+	}
 
-	trace, shutdown, err := tracing.NewRoot(prowjob)
-	fatal(err)
-	defer shutdown()
+	recordSkew(root, checkSkew(job))
+	if opts.CorrectSkew {
+		for _, s := range span.Nest(root) {
+			slog.Info("corrected span outside its parent", "span", s.Name)
+		}
+	}
+	for _, s := range span.FlagOverlaps(root) {
+		slog.Warn("span extends outside its parent", "span", s.Name)
+	}
+	span.FillGaps(root, minGap)
+	return root
+}
 
-	root := trace.Record("job", prowjob.Status.StartTime.Time, prowjob.Status.CompletionTime.Time)
+// addPod adds the pod of a job, and the containers that ran in it, under root.
+func addPod(root *span.Span, job jobArtifacts) {
+	src, prowjob, finished, pod, clone := job.src, job.prowjob, job.finished, job.pod, job.clone
 
-	podRecord := root.Recording("pod", pod.Pod.CreationTimestamp.Time, OrDefault(GetCondition(pod, "Ready"), fromEpoch(*finished.Timestamp)))
+	podSpan := root.Child("pod", pod.Pod.CreationTimestamp.Time, OrDefault(GetCondition(*pod, "Ready"), fromEpoch(*finished.Timestamp)))
+	if job.started.Node != "" {
+		podSpan.SetAttributes(semconv.K8SNodeName(job.started.Node))
+	}
+	if r := GetCondition(*pod, "Ready"); r != nil {
+		podSpan.Event("Ready", *r)
+	}
+	// The pod runs until its last container exits, which is usually well after it becomes ready.
+	for _, c := range pod.Pod.Status.ContainerStatuses {
+		if t := c.State.Terminated; t != nil && t.FinishedAt.After(podSpan.End) {
+			podSpan.End = t.FinishedAt.Time
+		}
+	}
 	for _, ev := range pod.Events {
 		// Record all events as events. TODO: extract some of these like "pulled image" into spans.
-		podRecord.Event(ev.Reason, ev.FirstTimestamp.Time, attribute.String("message", ev.Message))
+		podSpan.Event(ev.Reason, ev.FirstTimestamp.Time, attribute.String("message", ev.Message))
 	}
-	podCtx := podRecord.End()
 
-	if s := GetCondition(pod, "PodScheduled"); s != nil {
-		podCtx.Record("pod/schedule", pod.Pod.CreationTimestamp.Time, *s)
+	if s := GetCondition(*pod, "PodScheduled"); s != nil {
+		podSpan.Child("pod/schedule", pod.Pod.CreationTimestamp.Time, *s)
 	}
 
 	for _, init := range pod.Pod.Status.InitContainerStatuses {
 		if t := init.State.Terminated; t != nil {
-			initCtx := podCtx.Record("init/"+init.Name, t.StartedAt.Time, t.FinishedAt.Time)
+			initSpan := podSpan.Child("init/"+init.Name, t.StartedAt.Time, t.FinishedAt.Time)
+			initSpan.SetAttributes(containerAttributes(init)...)
 			switch init.Name {
 			case "clonerefs":
 				cur := t.StartedAt.Time
@@ -108,11 +231,11 @@ func prowjob(args []string) {
 					if rec.Refs.Org == "" {
 						continue
 					}
-					repoCtx := initCtx.Record(fmt.Sprintf("clone/%v/%v", rec.Refs.Org, rec.Refs.Repo), cur, cur.Add(rec.Duration))
+					repoSpan := initSpan.Child(fmt.Sprintf("clone/%v/%v", rec.Refs.Org, rec.Refs.Repo), cur, cur.Add(rec.Duration))
 					cmdTime := cur
 					cur = cur.Add(rec.Duration)
 					for _, cmd := range rec.Commands {
-						repoCtx.Record(classifyGitCommand(cmd.Command), cmdTime, cmdTime.Add(cmd.Duration))
+						repoSpan.Child(classifyGitCommand(cmd.Command), cmdTime, cmdTime.Add(cmd.Duration))
 						cmdTime = cmdTime.Add(cmd.Duration)
 					}
 				}
@@ -121,11 +244,83 @@ func prowjob(args []string) {
 	}
 	for _, c := range pod.Pod.Status.ContainerStatuses {
 		if t := c.State.Terminated; t != nil {
-			podCtx.Record("container/"+c.Name, t.StartedAt.Time, t.FinishedAt.Time)
+			containerSpan := podSpan.Child("container/"+c.Name, t.StartedAt.Time, t.FinishedAt.Time)
+			containerSpan.SetAttributes(containerAttributes(c)...)
+			if c.Name == "test" {
+				addSteps(containerSpan, src, prowjob, t.FinishedAt.Time)
+				// Spans recorded from inside the job are parented to the job span, not the container.
+				addSpool(root, src, t.FinishedAt.Time)
+			}
 		}
 	}
 }
 
+// containerAttributes describes how a terminated container ran.
+func containerAttributes(c model.ContainerStatus) []attribute.KeyValue {
+	t := c.State.Terminated
+	attrs := []attribute.KeyValue{attribute.Int("container.exit_code", int(t.ExitCode))}
+	if c.Image != "" {
+		attrs = append(attrs, semconv.ContainerImageName(c.Image))
+	}
+	if t.Reason != "" {
+		attrs = append(attrs, attribute.String("container.reason", t.Reason))
+	}
+	if c.RestartCount > 0 {
+		attrs = append(attrs, attribute.Int("container.restart_count", int(c.RestartCount)))
+	}
+	return attrs
+}
+
+// minGap is the shortest stretch of a span not covered by its children that is called out as unaccounted.
+const minGap = time.Second
+
+// addSteps adds the steps recorded from inside the job by `prow-tracing span` under parent.
+func addSteps(parent *span.Span, src artifacts.Source, pj model.ProwJob, end time.Time) {
+	r, err := src.Open("artifacts/" + steps.FileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	fatal(err)
+	defer r.Close()
+	tid, err := tracing.ProwJobIdentity(pj).TraceID()
+	fatal(err)
+	spans, err := steps.Read(r, tid.String())
+	fatal(err)
+	for _, s := range spans {
+		addStep(parent, s, end)
+	}
+}
+
+// addSpool adds the spans recorded from inside the job by `prow-tracing trace exec` under parent.
+func addSpool(parent *span.Span, src artifacts.Source, end time.Time) {
+	r, err := src.Open("artifacts/" + tracing.SpoolFileName)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	fatal(err)
+	defer r.Close()
+	spans, err := tracing.ReadSpool(r, end)
+	fatal(err)
+	parent.Children = append(parent.Children, spans...)
+}
+
+func addStep(parent *span.Span, s *steps.Span, end time.Time) {
+	attrs := []attribute.KeyValue{}
+	for k, v := range s.Attributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	if s.End.IsZero() {
+		// The step never completed, likely because the job was killed. Assume it ran until the container exited.
+		s.End = end
+		attrs = append(attrs, attribute.Bool("incomplete", true))
+	}
+	child := parent.Child(s.Name, s.Start, s.End)
+	child.SetAttributes(attrs...)
+	for _, c := range s.Children {
+		addStep(child, c, s.End)
+	}
+}
+
 func GetCondition(pod model.PodReport, cond string) *time.Time {
 	for _, c := range pod.Pod.Status.Conditions {
 		if c.Type == cond {
@@ -140,7 +335,7 @@ var commandRegexp = regexp.MustCompile("git (.+?)( |$)")
 func classifyGitCommand(command string) string {
 	m := commandRegexp.FindStringSubmatch(command)
 	if len(m) > 0 {
-		return m[0]
+		return strings.TrimSpace(m[0])
 	}
 	slog.Info("unknown git command", "cmd", command)
 	return "unknown"
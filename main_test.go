@@ -0,0 +1,597 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/artifacts"
+	"github.com/howardjohn/prow-tracing/internal/model"
+	"github.com/howardjohn/prow-tracing/internal/render"
+	"github.com/howardjohn/prow-tracing/internal/span"
+	"github.com/howardjohn/prow-tracing/internal/stats"
+	"github.com/howardjohn/prow-tracing/internal/tracing"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slices"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// TestGolden runs each job in testdata/jobs through the prowjob pipeline, comparing the exported trace to
+// testdata/golden/<job>.json. Run with -update to regenerate the golden files.
+func TestGolden(t *testing.T) {
+	jobs, err := os.ReadDir("testdata/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, j := range jobs {
+		name := j.Name()
+		t.Run(name, func(t *testing.T) {
+			runGolden(t, name, buildOptions{Links: true}, name+".json")
+		})
+	}
+}
+
+// TestGoldenCorrected runs the jobs with clock skew with skew correction enabled, comparing the exported
+// trace to testdata/golden/<job>.corrected.json.
+func TestGoldenCorrected(t *testing.T) {
+	for _, name := range []string{"clock-skew", "multi-repo-clone"} {
+		t.Run(name, func(t *testing.T) {
+			runGolden(t, name, buildOptions{CorrectSkew: true, Links: true}, name+".corrected.json")
+		})
+	}
+}
+
+// TestCriticalPath compares the critical path of each job in testdata/jobs to
+// testdata/golden/<job>.critical.txt.
+func TestCriticalPath(t *testing.T) {
+	jobs, err := os.ReadDir("testdata/jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, j := range jobs {
+		name := j.Name()
+		t.Run(name, func(t *testing.T) {
+			job, err := fetchJob(artifacts.Dir(filepath.Join("testdata/jobs", name)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got bytes.Buffer
+			if err := render.CriticalPath(&got, buildJob(job, buildOptions{})); err != nil {
+				t.Fatal(err)
+			}
+			compareGolden(t, filepath.Join("testdata/golden", name+".critical.txt"), got.Bytes())
+		})
+	}
+}
+
+// TestStats compares the statistics of the runs in testdata/history to testdata/golden/stats.txt. Run
+// 1004 has no completion time, so is skipped.
+func TestStats(t *testing.T) {
+	runs, err := fetchRuns(artifacts.Dir("testdata/history/unit-tests_istio"), runOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := stats.Samples{}
+	for _, job := range runs {
+		samples.Add(span.Durations(buildJob(job, buildOptions{})))
+	}
+	var got bytes.Buffer
+	if err := writeStats(&got, samples.Summarize(), "table"); err != nil {
+		t.Fatal(err)
+	}
+	compareGolden(t, "testdata/golden/stats.txt", got.Bytes())
+}
+
+// TestSampleWindows checks that compare splits the runs in testdata/history by when they started,
+// skipping run 1004, which has no completion time.
+func TestSampleWindows(t *testing.T) {
+	at := time.Date(2023, time.June, 20, 18, 0, 0, 0, time.UTC)
+	window := time.Since(at) + 24*time.Hour
+	baseline, current, err := sampleWindows(artifacts.Dir("testdata/history/unit-tests_istio"), at, window, buildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(baseline["job"]) != 1 || len(current["job"]) != 2 {
+		t.Fatalf("got %d baseline and %d current runs, want 1 and 2", len(baseline["job"]), len(current["job"]))
+	}
+}
+
+// TestDiff compares the diff of the passing and failing jobs to testdata/golden/diff.txt.
+func TestDiff(t *testing.T) {
+	var roots []*span.Span
+	for _, name := range []string{"passing", "failing"} {
+		job, err := fetchJob(artifacts.Dir(filepath.Join("testdata/jobs", name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, buildJob(job, buildOptions{}))
+	}
+	var got bytes.Buffer
+	if err := writeDiff(&got, span.Diff(roots[0], roots[1])); err != nil {
+		t.Fatal(err)
+	}
+	compareGolden(t, "testdata/golden/diff.txt", got.Bytes())
+}
+
+// TestCheckSkew checks that the clone records buildJob skips are not counted against clonerefs.
+func TestCheckSkew(t *testing.T) {
+	job, err := fetchJob(artifacts.Dir("testdata/jobs/multi-repo-clone"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := checkSkew(job); len(got) != 0 {
+		t.Fatalf("unexpected skew: %+v", got)
+	}
+	job.clone = append(job.clone, model.Record{Duration: time.Hour})
+	if got := checkSkew(job); len(got) != 0 {
+		t.Fatalf("record without refs counted as cloning: %+v", got)
+	}
+	job.clone = append(job.clone, model.Record{Refs: model.Refs{Org: "istio", Repo: "tools"}, Duration: time.Hour})
+	if got := checkSkew(job); len(got) != 1 || got[0].Earlier != "clone-records.end" {
+		t.Fatalf("got %+v, want clone records to end after clonerefs", got)
+	}
+}
+
+// TestCheckSkewWithoutPod checks that without podinfo.json, the job's start is compared to the ProwJob
+// directly, and checks that depend on the pod are skipped.
+func TestCheckSkewWithoutPod(t *testing.T) {
+	job, err := fetchJob(artifacts.Dir("testdata/jobs/multi-repo-clone"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.pod = nil
+	// Clone records can only be checked against the clonerefs container, which is in the pod.
+	job.clone = append(job.clone, model.Record{Refs: model.Refs{Org: "istio", Repo: "tools"}, Duration: time.Hour})
+	if got := checkSkew(job); len(got) != 0 {
+		t.Fatalf("unexpected skew: %+v", got)
+	}
+	job.started.Timestamp = job.prowjob.Status.StartTime.Add(-time.Minute).Unix()
+	if got := checkSkew(job); len(got) != 1 || got[0] != (skewFinding{Earlier: "prowjob.start", Later: "started.json", By: time.Minute}) {
+		t.Fatalf("got %+v, want started.json to be before the ProwJob started", got)
+	}
+}
+
+// TestBuildJobWithoutPod checks that a job without podinfo.json is still traced, with its steps under
+// the job span in place of the missing pod and containers.
+func TestBuildJobWithoutPod(t *testing.T) {
+	job, err := fetchJob(artifacts.Dir("testdata/jobs/passing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.pod = nil
+	root := buildJob(job, buildOptions{})
+	var children []string
+	for _, c := range root.Children {
+		if c.Name != "unaccounted" {
+			children = append(children, c.Name)
+		}
+	}
+	if got := strings.Join(children, ","); got != "build,test" {
+		t.Fatalf("got job children %v, want the steps", got)
+	}
+	if !root.End.Equal(job.prowjob.Status.CompletionTime.Time) {
+		t.Fatalf("got job end %v, want the ProwJob completion %v", root.End, job.prowjob.Status.CompletionTime)
+	}
+}
+
+// TestAttemptLinks checks that prior attempts are only looked up when asked for.
+func TestAttemptLinks(t *testing.T) {
+	dir := t.TempDir()
+	for i, build := range []string{"100", "200"} {
+		pj, err := artifacts.Fetch[model.ProwJob](artifacts.Dir("testdata/jobs/passing"), "prowjob.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pj.Labels["prow.k8s.io/id"] = fmt.Sprintf("00000000-0000-0000-0000-%012d", i)
+		pj.Labels["prow.k8s.io/build-id"] = build
+		b, err := json.Marshal(pj)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Join(dir, build), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, build, "prowjob.json"), b, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	job, err := fetchJob(artifacts.Dir("testdata/jobs/passing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.src = artifacts.Dir(filepath.Join(dir, "300"))
+	job.prowjob.Labels["prow.k8s.io/build-id"] = "300"
+
+	if root := buildJob(job, buildOptions{}); len(root.Links) != 0 {
+		t.Fatalf("got %d links without Links set, want none", len(root.Links))
+	}
+	root := buildJob(job, buildOptions{Links: true})
+	if len(root.Links) != 2 {
+		t.Fatalf("got %d links, want one per prior attempt", len(root.Links))
+	}
+	attempt := int64(0)
+	for _, kv := range root.Attributes {
+		if kv.Key == "prow.attempt" {
+			attempt = kv.Value.AsInt64()
+		}
+	}
+	if attempt != 3 {
+		t.Fatalf("got attempt %d, want 3", attempt)
+	}
+}
+
+func runGolden(t *testing.T, name string, opts buildOptions, golden string) {
+	t.Helper()
+	job, err := fetchJob(artifacts.Dir(filepath.Join("testdata/jobs", name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := tracetest.NewInMemoryExporter()
+	if err := tracing.Export(job.prowjob, buildJob(job, opts), exp); err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.MarshalIndent(goldenTrace(exp.GetSpans().Snapshots()), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	compareGolden(t, filepath.Join("testdata/golden", golden), got)
+}
+
+func compareGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run with -update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("output does not match %v; run with -update to accept it. Got:\n%s", path, got)
+	}
+}
+
+type goldenFile struct {
+	TraceID  string            `json:"traceId"`
+	Resource map[string]string `json:"resource"`
+	Spans    []*goldenSpan     `json:"spans"`
+}
+
+type goldenSpan struct {
+	Name       string            `json:"name"`
+	SpanID     string            `json:"spanId"`
+	Start      time.Time         `json:"start"`
+	End        time.Time         `json:"end"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Events     []string          `json:"events,omitempty"`
+	Links      []string          `json:"links,omitempty"`
+	Status     string            `json:"status,omitempty"`
+	Children   []*goldenSpan     `json:"children,omitempty"`
+}
+
+// goldenTrace converts exported spans into a stable tree, nested by parent span ID. Spans whose parent
+// was not exported are listed at the top level.
+func goldenTrace(spans []tracesdk.ReadOnlySpan) goldenFile {
+	res := goldenFile{Resource: map[string]string{}}
+	nodes := map[trace.SpanID]*goldenSpan{}
+	for _, s := range spans {
+		res.TraceID = s.SpanContext().TraceID().String()
+		for _, a := range s.Resource().Attributes() {
+			res.Resource[string(a.Key)] = a.Value.Emit()
+		}
+		g := &goldenSpan{
+			Name:   s.Name(),
+			SpanID: s.SpanContext().SpanID().String(),
+			Start:  s.StartTime().UTC(),
+			End:    s.EndTime().UTC(),
+		}
+		for _, a := range s.Attributes() {
+			if g.Attributes == nil {
+				g.Attributes = map[string]string{}
+			}
+			g.Attributes[string(a.Key)] = a.Value.Emit()
+		}
+		for _, ev := range s.Events() {
+			g.Events = append(g.Events, ev.Time.UTC().Format(time.RFC3339)+" "+ev.Name)
+		}
+		for _, l := range s.Links() {
+			g.Links = append(g.Links, l.SpanContext.TraceID().String()+"/"+l.SpanContext.SpanID().String())
+		}
+		if s.Status().Code != 0 {
+			g.Status = s.Status().Code.String() + ": " + s.Status().Description
+		}
+		nodes[s.SpanContext().SpanID()] = g
+	}
+	for _, s := range spans {
+		g := nodes[s.SpanContext().SpanID()]
+		if p, f := nodes[s.Parent().SpanID()]; f {
+			p.Children = append(p.Children, g)
+		} else {
+			res.Spans = append(res.Spans, g)
+		}
+	}
+	sortGolden(res.Spans)
+	return res
+}
+
+func sortGolden(spans []*goldenSpan) {
+	sort.Slice(spans, func(i, j int) bool {
+		if !spans[i].Start.Equal(spans[j].Start) {
+			return spans[i].Start.Before(spans[j].Start)
+		}
+		return spans[i].Name < spans[j].Name
+	})
+	for _, s := range spans {
+		sortGolden(s.Children)
+	}
+}
+
+// TestPoll checks that the poller processes each run once it finishes, and only once.
+func TestPoll(t *testing.T) {
+	// setup returns a poller over a history, and functions to poll it and check its progress.
+	setup := func(t *testing.T, dir string, backfill int) (poll func() []string, check func(got []string, want progress, wantRuns int)) {
+		p := newPoller([]string{dir}, backfill)
+		poll = func() []string {
+			var got []string
+			p.poll(func(_ string, job jobArtifacts) error {
+				got = append(got, job.prowjob.Name)
+				return nil
+			})
+			return got
+		}
+		check = func(got []string, want progress, wantRuns int) {
+			t.Helper()
+			if len(got) != wantRuns {
+				t.Fatalf("got %d runs, want %d: %v", len(got), wantRuns, got)
+			}
+			prog := *p.progress[dir]
+			if prog.Mark != want.Mark || fmt.Sprint(prog.Done) != fmt.Sprint(want.Done) || fmt.Sprint(prog.Attempts) != fmt.Sprint(want.Attempts) {
+				t.Fatalf("got progress %+v, want %+v", prog, want)
+			}
+		}
+		return poll, check
+	}
+
+	t.Run("backfill", func(t *testing.T) {
+		dir := t.TempDir()
+		linkRun(t, dir, "1001", "passing")
+		linkRun(t, dir, "1003", "failing")
+		// 1002 is still running, so has no finished.json yet.
+		if err := os.Mkdir(filepath.Join(dir, "1002"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		poll, check := setup(t, dir, 10)
+
+		check(poll(), progress{Mark: 1001, Done: []int64{1003}}, 2)
+		check(poll(), progress{Mark: 1001, Done: []int64{1003}}, 0)
+		linkRun(t, dir, "1002", "clock-skew")
+		check(poll(), progress{Mark: 1003}, 1)
+		check(poll(), progress{Mark: 1003}, 0)
+	})
+
+	t.Run("no backfill", func(t *testing.T) {
+		dir := t.TempDir()
+		linkRun(t, dir, "1001", "passing")
+		linkRun(t, dir, "1002", "failing")
+		// 1003 and 1004 are still running when the job is first polled.
+		for _, run := range []string{"1003", "1004"} {
+			if err := os.Mkdir(filepath.Join(dir, run), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		poll, check := setup(t, dir, 0)
+
+		check(poll(), progress{Mark: 1002}, 0)
+		linkRun(t, dir, "1004", "clock-skew")
+		check(poll(), progress{Mark: 1002, Done: []int64{1004}}, 1)
+		linkRun(t, dir, "1003", "aborted")
+		check(poll(), progress{Mark: 1004}, 1)
+	})
+
+	t.Run("podinfo uploaded late", func(t *testing.T) {
+		dir := t.TempDir()
+		copyRun(t, dir, "1001", "passing", "podinfo.json")
+		poll, check := setup(t, dir, 10)
+
+		check(poll(), progress{Attempts: map[int64]int{1001: 1}}, 0)
+		copyRun(t, dir, "1001", "passing")
+		check(poll(), progress{Mark: 1001}, 1)
+	})
+
+	t.Run("podinfo never uploaded", func(t *testing.T) {
+		dir := t.TempDir()
+		linkRun(t, dir, "1001", "missing-podinfo")
+		poll, check := setup(t, dir, 10)
+
+		for i := 1; i < maxAttempts; i++ {
+			check(poll(), progress{Attempts: map[int64]int{1001: i}}, 0)
+		}
+		// Rather than waiting forever, the run is processed without it.
+		check(poll(), progress{Mark: 1001}, 1)
+	})
+
+	t.Run("never finished", func(t *testing.T) {
+		dir := t.TempDir()
+		// Started long ago, and lost before finishing.
+		copyRun(t, dir, "1001", "passing", "finished.json")
+		poll, check := setup(t, dir, 10)
+
+		check(poll(), progress{Mark: 1001}, 0)
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		dir := t.TempDir()
+		// finished.json is uploaded before prowjob.json.
+		copyRun(t, dir, "1001", "passing", "prowjob.json")
+		linkRun(t, dir, "1002", "failing")
+		poll, check := setup(t, dir, 10)
+
+		check(poll(), progress{Done: []int64{1002}, Attempts: map[int64]int{1001: 1}}, 1)
+		copyRun(t, dir, "1001", "passing")
+		check(poll(), progress{Mark: 1002}, 1)
+	})
+}
+
+// TestWatch checks that watch traces each run once, resuming from saved progress after a restart.
+func TestWatch(t *testing.T) {
+	t.Setenv("OTEL_METRICS_EXPORTER", "none")
+	// watchOnce runs watch --once over dir, returning the traces it exported.
+	watchOnce := func(t *testing.T, dir, state string, backfill int) map[trace.TraceID]bool {
+		t.Helper()
+		p := newPoller([]string{dir}, backfill)
+		if err := p.load(state); err != nil {
+			t.Fatal(err)
+		}
+		exp := tracetest.NewInMemoryExporter()
+		p.poll(traceRun(buildOptions{}, exp))
+		if err := p.save(state); err != nil {
+			t.Fatal(err)
+		}
+		traces := map[trace.TraceID]bool{}
+		for _, s := range exp.GetSpans().Snapshots() {
+			traces[s.SpanContext().TraceID()] = true
+		}
+		return traces
+	}
+
+	t.Run("restart", func(t *testing.T) {
+		dir, state := t.TempDir(), filepath.Join(t.TempDir(), "state.json")
+		linkRun(t, dir, "1001", "passing")
+		if err := os.Mkdir(filepath.Join(dir, "1002"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if got := watchOnce(t, dir, state, 10); len(got) != 1 {
+			t.Fatalf("got %d traces, want 1", len(got))
+		}
+		if got := watchOnce(t, dir, state, 10); len(got) != 0 {
+			t.Fatalf("got %d traces after restart, want 0", len(got))
+		}
+		linkRun(t, dir, "1002", "clock-skew")
+		linkRun(t, dir, "1003", "failing")
+		if got := watchOnce(t, dir, state, 10); len(got) != 2 {
+			t.Fatalf("got %d traces, want 2", len(got))
+		}
+	})
+
+	t.Run("finished after start", func(t *testing.T) {
+		dir, state := t.TempDir(), filepath.Join(t.TempDir(), "state.json")
+		linkRun(t, dir, "1001", "passing")
+		// 1002 is running when the watcher starts, without backfill.
+		if err := os.Mkdir(filepath.Join(dir, "1002"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if got := watchOnce(t, dir, state, 0); len(got) != 0 {
+			t.Fatalf("got %d traces, want 0", len(got))
+		}
+		// It finishes, but podinfo.json is not uploaded yet.
+		copyRun(t, dir, "1002", "clock-skew", "podinfo.json")
+		if got := watchOnce(t, dir, state, 0); len(got) != 0 {
+			t.Fatalf("got %d traces before podinfo.json was uploaded, want 0", len(got))
+		}
+		copyRun(t, dir, "1002", "clock-skew")
+		got := watchOnce(t, dir, state, 0)
+		job, err := fetchJob(artifacts.Dir("testdata/jobs/clock-skew"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tid, err := tracing.ProwJobIdentity(job.prowjob).TraceID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || !got[tid] {
+			t.Fatalf("got traces %v, want only the run that finished", got)
+		}
+		if got := watchOnce(t, dir, state, 0); len(got) != 0 {
+			t.Fatalf("got %d traces after restart, want 0", len(got))
+		}
+	})
+}
+
+// copyRun adds a run to the history in dir, with a copy of the artifacts of a job in testdata/jobs, except
+// those listed in skip.
+func copyRun(t *testing.T, dir, run, job string, skip ...string) {
+	t.Helper()
+	src := filepath.Join("testdata/jobs", job)
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(skip, rel) {
+			return nil
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, run, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(dst, b, 0o644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// linkRun adds a run to the history in dir, with the artifacts of a job in testdata/jobs.
+func linkRun(t *testing.T, dir, run, job string) {
+	t.Helper()
+	target, err := filepath.Abs(filepath.Join("testdata/jobs", job))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = os.RemoveAll(filepath.Join(dir, run))
+	if err := os.Symlink(target, filepath.Join(dir, run)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestServeMetrics checks that recorded jobs are served in the Prometheus format.
+func TestServeMetrics(t *testing.T) {
+	handler, rec, err := newMetricsHandler([]float64{60, 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := fetchJob(artifacts.Dir("testdata/jobs/failing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Record(context.Background(), jobMetrics(job, buildJob(job, buildOptions{})))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`prow_job_runs_total{`,
+		`prow_result="FAILURE"`,
+		`prow_job_duration_bucket{`,
+		`le="600"`,
+		`prow_job_container_duration_count{k8s_container_name="test"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
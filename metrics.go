@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/metrics"
+	"github.com/howardjohn/prow-tracing/internal/span"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// jobMetrics describes a job run for metrics, from its artifacts and trace.
+func jobMetrics(job jobArtifacts, root *span.Span) metrics.Job {
+	pj := job.prowjob
+	repo := ""
+	if r := pj.Spec.Refs; r != nil {
+		repo = r.Org + "/" + r.Repo
+	}
+	m := metrics.Job{
+		Attributes: []attribute.KeyValue{
+			attribute.String("prow.job", pj.Labels["prow.k8s.io/job"]),
+			attribute.String("prow.type", pj.Labels["prow.k8s.io/type"]),
+			attribute.String("prow.repo", repo),
+			attribute.String("prow.cluster", pj.Spec.Cluster),
+			attribute.String("prow.result", jobResult(job)),
+		},
+		Total:      root.Duration(),
+		Containers: map[string]time.Duration{},
+	}
+	if pj.Status.PendingTime != nil {
+		m.Queue = pj.Status.PendingTime.Sub(pj.Status.StartTime.Time)
+	}
+	root.Walk(func(s, _ *span.Span, _ int) bool {
+		switch {
+		case s.Name == "pod/schedule":
+			m.Schedule = s.Duration()
+		case s.Name == "init/clonerefs":
+			m.Clone = s.Duration()
+		case strings.HasPrefix(s.Name, "container/"):
+			m.Containers[strings.TrimPrefix(s.Name, "container/")] = s.Duration()
+		}
+		return true
+	})
+	return m
+}
+
+// jobResult returns the result of a job, as reported in finished.json.
+func jobResult(job jobArtifacts) string {
+	if r := job.finished.Result; r != "" {
+		return r
+	}
+	if p := job.finished.Passed; p != nil && *p {
+		return "SUCCESS"
+	}
+	return "FAILURE"
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/artifacts"
+	"github.com/howardjohn/prow-tracing/internal/model"
+	"golang.org/x/exp/slog"
+)
+
+// poller finds runs of jobs that have finished since it last looked.
+type poller struct {
+	histories map[string]artifacts.Source
+	// progress is how far through each history the poller is, keyed by the job it was given.
+	progress map[string]*progress
+	// backfill is how many of the most recent finished runs of a job to process the first time it is
+	// polled. Runs still in progress then are always processed once they finish.
+	backfill int
+}
+
+// progress tracks which runs of a job have been processed.
+type progress struct {
+	// Mark is the high-water mark: every run up to and including it has been processed.
+	Mark int64 `json:"mark"`
+	// Done are runs after Mark that have been processed, as they finished before a run started
+	// before them.
+	Done []int64 `json:"done,omitempty"`
+	// Attempts counts the polls that failed to process each run after Mark, so that a run that keeps
+	// failing is eventually given up on.
+	Attempts map[int64]int `json:"attempts,omitempty"`
+}
+
+const (
+	// maxAttempts is how many polls may fail to process a run before it is given up on. This also bounds
+	// how long a finished run waits for podinfo.json, which crier uploads separately.
+	maxAttempts = 10
+	// maxRunAge is how long a run may go without finishing before it is given up on. Prow aborts jobs
+	// long before this, so such a run was lost, such as by its pod being deleted.
+	maxRunAge = 24 * time.Hour
+)
+
+var (
+	// errIncomplete is returned for finished runs whose artifacts are not all uploaded yet.
+	errIncomplete = errors.New("podinfo.json is not uploaded yet")
+	// errAbandoned is returned for runs that will never finish.
+	errAbandoned = fmt.Errorf("run did not finish within %v", maxRunAge)
+)
+
+func newPoller(jobs []string, backfill int) *poller {
+	p := &poller{
+		histories: map[string]artifacts.Source{},
+		progress:  map[string]*progress{},
+		backfill:  backfill,
+	}
+	for _, j := range jobs {
+		p.histories[j] = jobHistory(j)
+	}
+	return p
+}
+
+// poll calls handle for each run that has finished since the last poll, oldest first. Runs that are
+// still running, whose artifacts cannot be fetched yet, or that handle fails on, are picked up by a later
+// poll, up to maxAttempts times.
+func (p *poller) poll(handle func(job string, run jobArtifacts) error) {
+	for job, history := range p.histories {
+		runs, err := listRuns(history)
+		if err != nil {
+			slog.Warn("failed to list runs", "job", job, "err", err)
+			continue
+		}
+		prog, f := p.progress[job]
+		if !f {
+			prog = &progress{Mark: p.initialMark(history, runs)}
+			p.progress[job] = prog
+		}
+		if prog.Attempts == nil {
+			prog.Attempts = map[int64]int{}
+		}
+		done := map[int64]bool{}
+		for _, id := range prog.Done {
+			done[id] = true
+		}
+		// retry records a failed attempt at a run, returning whether it should be tried again.
+		retry := func(r jobRun, err error) bool {
+			prog.Attempts[r.id]++
+			if prog.Attempts[r.id] < maxAttempts {
+				slog.Warn("failed to process run, will retry", "job", job, "run", r.name, "err", err)
+				return true
+			}
+			slog.Warn("giving up on run", "job", job, "run", r.name, "attempts", prog.Attempts[r.id], "err", err)
+			return false
+		}
+
+		for _, r := range runs {
+			if r.id <= prog.Mark || done[r.id] {
+				continue
+			}
+			finished, err := p.finished(history, r)
+			switch {
+			case finished == nil && err == nil:
+				continue
+			case errors.Is(err, errAbandoned):
+				slog.Warn("giving up on run", "job", job, "run", r.name, "err", err)
+				done[r.id] = true
+				continue
+			case err != nil:
+				if retry(r, err) {
+					continue
+				}
+				if finished == nil {
+					done[r.id] = true
+					continue
+				}
+				// Waiting longer for the rest of the artifacts is unlikely to help, so use what there is.
+			}
+			if err := p.handle(job, *finished, handle); err != nil && retry(r, err) {
+				continue
+			}
+			done[r.id] = true
+		}
+
+		for _, r := range runs {
+			if r.id <= prog.Mark {
+				continue
+			}
+			if !done[r.id] {
+				break
+			}
+			prog.Mark = r.id
+			delete(done, r.id)
+		}
+		prog.Done = prog.Done[:0]
+		for id := range done {
+			prog.Done = append(prog.Done, id)
+		}
+		sort.Slice(prog.Done, func(i, j int) bool { return prog.Done[i] < prog.Done[j] })
+		for id := range prog.Attempts {
+			if id <= prog.Mark || done[id] {
+				delete(prog.Attempts, id)
+			}
+		}
+	}
+}
+
+// initialMark returns the mark of a job polled for the first time: the newest finished run that is not
+// backfilled. Every run after it, including those still running, is processed once it finishes.
+func (p *poller) initialMark(history artifacts.Source, runs []jobRun) int64 {
+	backfilled := 0
+	for i := len(runs) - 1; i >= 0; i-- {
+		src, err := openRun(history, runs[i].name)
+		if err != nil {
+			continue
+		}
+		if f, err := hasFinished(src); err != nil || !f {
+			continue
+		}
+		if backfilled == p.backfill {
+			return runs[i].id
+		}
+		backfilled++
+	}
+	return 0
+}
+
+// finished returns the artifacts of a run, or nil if it has not finished yet. It returns errIncomplete,
+// along with the artifacts there are, if some are yet to be uploaded, and errAbandoned for a run that
+// will never finish.
+func (p *poller) finished(history artifacts.Source, r jobRun) (*jobArtifacts, error) {
+	src, err := openRun(history, r.name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := hasFinished(src)
+	if err != nil {
+		return nil, err
+	}
+	if !f {
+		if started, err := artifacts.Fetch[model.Started](src, "started.json"); err == nil && time.Since(fromEpoch(started.Timestamp)) > maxRunAge {
+			return nil, errAbandoned
+		}
+		return nil, nil
+	}
+	job, err := fetchJob(src)
+	if err != nil {
+		return nil, err
+	}
+	if job.pod == nil {
+		return &job, errIncomplete
+	}
+	return &job, nil
+}
+
+// hasFinished returns whether a run has finished, which is when it uploads finished.json.
+func hasFinished(src artifacts.Source) (bool, error) {
+	f, err := src.Open("finished.json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	f.Close()
+	return true, nil
+}
+
+// handle calls handle for a run, recovering from any panic so one bad run does not stop the poller. As
+// the run's artifacts are at fault, it is not retried.
+func (p *poller) handle(job string, run jobArtifacts, handle func(job string, run jobArtifacts) error) error {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("failed to process run", "job", job, "err", r)
+		}
+	}()
+	return handle(job, run)
+}
+
+// load restores progress saved by save. Jobs not in the file start afresh, as if newly polled.
+func (p *poller) load(path string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	progress := map[string]*progress{}
+	if err := json.Unmarshal(b, &progress); err != nil {
+		return fmt.Errorf("invalid progress in %v: %v", path, err)
+	}
+	for job, prog := range progress {
+		if _, f := p.histories[job]; f {
+			p.progress[job] = prog
+		}
+	}
+	return nil
+}
+
+// save writes the progress through each job to path, replacing it atomically so an interrupted save
+// does not lose earlier progress.
+func (p *poller) save(path string) error {
+	b, err := json.MarshalIndent(p.progress, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/howardjohn/prow-tracing/internal/tracing"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/exp/slog"
+)
+
+const replayUsage = `usage:
+  prow-tracing replay FILE...`
+
+// replay re-exports saved trace files, as written by the file exporter, to the configured exporter.
+func replay(args []string) {
+	if len(args) == 0 {
+		log.Fatal(replayUsage)
+	}
+	spans := []tracesdk.ReadOnlySpan{}
+	for _, path := range args {
+		f, err := os.Open(path)
+		fatal(err)
+		s, err := tracing.ReadTraceFile(f)
+		f.Close()
+		fatal(err)
+		slog.Info("read trace file", "path", path, "spans", len(s))
+		spans = append(spans, s...)
+	}
+	fatal(tracing.ExportSpans(spans))
+}
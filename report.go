@@ -0,0 +1,25 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/howardjohn/prow-tracing/internal/render"
+	"golang.org/x/exp/slog"
+)
+
+// report renders the trace of a job as a self-contained HTML page, without exporting it anywhere.
+func report(args []string) {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	out := flags.String("o", "trace.html", "file to write the report to")
+	opts := addBuildFlags(flags)
+	fatal(flags.Parse(args))
+	job, err := fetchJob(openJob(flags.Arg(0)))
+	fatal(err)
+
+	f, err := os.Create(*out)
+	fatal(err)
+	defer f.Close()
+	fatal(render.HTML(f, buildJob(job, *opts)))
+	slog.Info("wrote report", "path", *out)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/howardjohn/prow-tracing/internal/artifacts"
+	"github.com/howardjohn/prow-tracing/internal/model"
+	"github.com/howardjohn/prow-tracing/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+)
+
+// priorAttempts finds earlier runs of the same presubmit job for the same PR and SHA, such as those
+// from a /retest, ordered from oldest to newest. Runs of a PR job are stored alongside each other, as
+// pr-logs/pull/<org_repo>/<pr>/<job>/<build>, so these are found by listing the job's directory.
+func priorAttempts(src artifacts.Source, pj model.ProwJob) []model.ProwJob {
+	pull := pj.Labels["prow.k8s.io/refs.pull"]
+	if pull == "" {
+		return nil
+	}
+	build, err := strconv.ParseInt(pj.Labels["prow.k8s.io/build-id"], 10, 64)
+	if err != nil {
+		slog.Warn("cannot find prior attempts, invalid build ID", "build", pj.Labels["prow.k8s.io/build-id"])
+		return nil
+	}
+	jobDir := src.Sub("..")
+	runs, err := jobDir.List()
+	if err != nil {
+		slog.Warn("failed to list prior attempts", "err", err)
+		return nil
+	}
+	ids := []int64{}
+	for _, r := range runs {
+		id, err := strconv.ParseInt(r, 10, 64)
+		if err != nil || id >= build {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	res := []model.ProwJob{}
+	for _, id := range ids {
+		prior, err := artifacts.Fetch[model.ProwJob](jobDir.Sub(strconv.FormatInt(id, 10)), "prowjob.json")
+		if err != nil {
+			slog.Warn("failed to fetch prior attempt", "build", id, "err", err)
+			continue
+		}
+		if prior.Labels["prow.k8s.io/job"] != pj.Labels["prow.k8s.io/job"] || prior.Labels["prow.k8s.io/refs.pull"] != pull {
+			continue
+		}
+		if sha, priorSha := pullSHA(pj), pullSHA(prior); sha != "" && priorSha != "" && sha != priorSha {
+			continue
+		}
+		res = append(res, prior)
+	}
+	return res
+}
+
+// attemptLinks returns span links to the root spans of prior attempts of a job.
+func attemptLinks(prior []model.ProwJob) []trace.Link {
+	links := []trace.Link{}
+	for i, p := range prior {
+		sc, err := tracing.ProwJobIdentity(p).RootSpanContext()
+		if err != nil {
+			slog.Warn("cannot link to prior attempt", "name", p.Name, "err", err)
+			continue
+		}
+		links = append(links, trace.Link{
+			SpanContext: sc,
+			Attributes: []attribute.KeyValue{
+				attribute.String("link.type", "retry"),
+				attribute.Int("prow.attempt", i+1),
+			},
+		})
+	}
+	return links
+}
+
+func pullSHA(pj model.ProwJob) string {
+	if pj.Spec.Refs == nil || len(pj.Spec.Refs.Pulls) == 0 {
+		return ""
+	}
+	return pj.Spec.Refs.Pulls[0].SHA
+}
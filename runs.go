@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/artifacts"
+	"github.com/howardjohn/prow-tracing/internal/gcs"
+	"golang.org/x/exp/slog"
+)
+
+// runOptions selects which runs of a job to use, newest first.
+type runOptions struct {
+	// Count is the most runs to use.
+	Count int
+	// Since, if set, skips runs that started longer ago than this.
+	Since time.Duration
+}
+
+// jobHistory returns the directory listing the runs of a job. job may be a local directory or GCS path
+// holding one directory per run, or the name of a job. Presubmit runs are spread across the directories
+// of each PR, so are found via the pr-logs/directory index; periodic and postsubmit runs are stored
+// together under logs.
+func jobHistory(job string) artifacts.Source {
+	if fi, err := os.Stat(job); err == nil && fi.IsDir() {
+		return artifacts.Dir(job)
+	}
+	if strings.Contains(job, "/") {
+		return gcs.NewClient(job)
+	}
+	index := gcs.NewClient("istio-prow/pr-logs/directory/" + job)
+	if runs, err := index.List(); err == nil && len(runs) > 0 {
+		return index
+	}
+	return gcs.NewClient("istio-prow/logs/" + job)
+}
+
+// fetchRuns fetches the runs of a job listed in history, newest first. Runs that cannot be fetched, such
+// as those still running, are skipped, as are those missing the completion times traces end at.
+func fetchRuns(history artifacts.Source, opts runOptions) ([]jobArtifacts, error) {
+	runs, err := listRuns(history)
+	if err != nil {
+		return nil, err
+	}
+	// Newest first.
+	sort.Slice(runs, func(i, j int) bool { return runs[i].id > runs[j].id })
+
+	res := []jobArtifacts{}
+	for _, r := range runs {
+		if opts.Count > 0 && len(res) >= opts.Count {
+			break
+		}
+		src, err := openRun(history, r.name)
+		if err != nil {
+			slog.Warn("skipping run", "run", r.name, "err", err)
+			continue
+		}
+		job, err := fetchJob(src)
+		if err != nil {
+			slog.Warn("skipping run", "run", r.name, "err", err)
+			continue
+		}
+		if opts.Since > 0 && time.Since(fromEpoch(job.started.Timestamp)) > opts.Since {
+			break
+		}
+		if job.prowjob.Status.CompletionTime == nil || job.finished.Timestamp == nil {
+			slog.Warn("skipping run", "run", r.name, "err", "run has no completion time")
+			continue
+		}
+		res = append(res, job)
+	}
+	return res, nil
+}
+
+// jobRun is an entry in a job history.
+type jobRun struct {
+	// id is the build ID of the run. These increase over time.
+	id   int64
+	name string
+}
+
+// listRuns returns the runs in a job history, oldest first.
+func listRuns(history artifacts.Source) ([]jobRun, error) {
+	names, err := history.List()
+	if err != nil {
+		return nil, err
+	}
+	runs := []jobRun{}
+	for _, n := range names {
+		id, err := strconv.ParseInt(strings.TrimSuffix(n, ".txt"), 10, 64)
+		if err != nil {
+			continue
+		}
+		runs = append(runs, jobRun{id, n})
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].id < runs[j].id })
+	return runs, nil
+}
+
+// openRun returns the artifacts of a run listed in history. Entries in the pr-logs/directory index are
+// files holding the GCS location of the run, rather than the run itself.
+func openRun(history artifacts.Source, name string) (artifacts.Source, error) {
+	if !strings.HasSuffix(name, ".txt") {
+		return history.Sub(name), nil
+	}
+	r, err := history.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return openJob(strings.TrimPrefix(strings.TrimSpace(string(b)), "gs://")), nil
+}
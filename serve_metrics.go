@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"golang.org/x/exp/slog"
+)
+
+const serveMetricsUsage = `usage:
+  prow-tracing serve-metrics [--listen ADDR] [--interval DURATION] [--buckets SECONDS,...] JOB...`
+
+// serveMetrics polls jobs for runs as they finish, serving metrics about them for Prometheus to scrape.
+func serveMetrics(args []string) {
+	flags := flag.NewFlagSet("serve-metrics", flag.ExitOnError)
+	listen := flags.String("listen", ":9090", "address to serve /metrics on")
+	interval := flags.Duration("interval", time.Minute, "how often to poll for new runs")
+	buckets := flags.String("buckets", "", "comma separated histogram bucket boundaries, in seconds; defaults to 1s through 4h")
+	backfill := flags.Int("backfill", 0, "number of each job's most recent finished runs to process on startup; runs in progress are always processed")
+	opts := addBuildFlags(flags)
+	fatal(flags.Parse(args))
+	if flags.NArg() == 0 {
+		log.Fatal(serveMetricsUsage)
+	}
+	bounds, err := parseBuckets(*buckets)
+	fatal(err)
+
+	handler, rec, err := newMetricsHandler(bounds)
+	fatal(err)
+	p := newPoller(flags.Args(), *backfill)
+	go func() {
+		for {
+			p.poll(func(name string, job jobArtifacts) error {
+				slog.Info("recording run", "job", name, "prowjob", job.prowjob.Name)
+				rec.Record(context.Background(), jobMetrics(job, buildJob(job, *opts)))
+				return nil
+			})
+			time.Sleep(*interval)
+		}
+	}()
+
+	http.Handle("/metrics", handler)
+	slog.Info("serving metrics", "address", *listen)
+	fatal(http.ListenAndServe(*listen, nil))
+}
+
+// newMetricsHandler returns a handler serving everything recorded by the returned Recorder in the
+// Prometheus format.
+func newMetricsHandler(buckets []float64) (http.Handler, *metrics.Recorder, error) {
+	reg := prometheus.NewRegistry()
+	exp, err := otelprom.New(otelprom.WithRegisterer(reg))
+	if err != nil {
+		return nil, nil, err
+	}
+	rec, err := metrics.NewRecorder(metrics.NewMeterProvider(exp, buckets))
+	if err != nil {
+		return nil, nil, err
+	}
+	return promhttp.HandlerFor(reg, promhttp.HandlerOpts{}), rec, nil
+}
+
+// parseBuckets parses comma separated bucket boundaries, returning metrics.DefaultBuckets if there are none.
+func parseBuckets(s string) ([]float64, error) {
+	if s == "" {
+		return metrics.DefaultBuckets, nil
+	}
+	var res []float64
+	for _, b := range strings.Split(s, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(b), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q: %v", b, err)
+		}
+		if len(res) > 0 && f <= res[len(res)-1] {
+			return nil, fmt.Errorf("buckets must be increasing, got %v after %v", f, res[len(res)-1])
+		}
+		res = append(res, f)
+	}
+	return res, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/model"
+	"github.com/howardjohn/prow-tracing/internal/span"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/exp/slog"
+)
+
+// skewTolerance is how far out of order two timestamps may be before it is reported as clock skew.
+// started.json and finished.json only have second precision, so anything finer is noise.
+const skewTolerance = time.Second
+
+// skewFinding is a pair of timestamps, from different clocks, that are in the wrong order.
+type skewFinding struct {
+	// Earlier should have happened before Later, but happened By after it.
+	Earlier, Later string
+	By             time.Duration
+}
+
+// timestamp is a time, named after where it came from.
+type timestamp struct {
+	name string
+	t    time.Time
+}
+
+// checkSkew compares timestamps that come from different clocks, returning those that are out of order.
+// The ProwJob times come from the prow controller, the pod times from the kubelet, started.json and
+// finished.json from inside the pod, and the clone records only hold durations, relative to the start
+// of clonerefs.
+func checkSkew(job jobArtifacts) []skewFinding {
+	pj, pod := job.prowjob, job.pod
+	var orderings [][2]timestamp
+	order := func(earlier, later timestamp) {
+		if !earlier.t.IsZero() && !later.t.IsZero() {
+			orderings = append(orderings, [2]timestamp{earlier, later})
+		}
+	}
+
+	pjStart := timestamp{"prowjob.start", pj.Status.StartTime.Time}
+	started := timestamp{"started.json", fromEpoch(job.started.Timestamp)}
+	if pod != nil {
+		podCreated := timestamp{"pod.created", pod.Pod.CreationTimestamp.Time}
+		order(pjStart, podCreated)
+		order(podCreated, started)
+	} else {
+		order(pjStart, started)
+	}
+	if job.finished.Timestamp != nil {
+		finished := timestamp{"finished.json", fromEpoch(*job.finished.Timestamp)}
+		order(started, finished)
+		if pj.Status.CompletionTime != nil {
+			order(finished, timestamp{"prowjob.completion", pj.Status.CompletionTime.Time})
+		}
+		for _, c := range podContainers(pod) {
+			if t := c.State.Terminated; t != nil && c.Name == "test" {
+				order(timestamp{"container/test.finished", t.FinishedAt.Time}, finished)
+			}
+		}
+	}
+	for _, c := range podInitContainers(pod) {
+		if t := c.State.Terminated; t != nil && c.Name == "clonerefs" {
+			cloned := t.StartedAt.Time
+			for _, rec := range job.clone {
+				// Like buildJob, skip the global git config that precedes the repos.
+				if rec.Refs.Org == "" {
+					continue
+				}
+				cloned = cloned.Add(rec.Duration)
+			}
+			order(timestamp{"clone-records.end", cloned}, timestamp{"init/clonerefs.finished", t.FinishedAt.Time})
+		}
+	}
+
+	var res []skewFinding
+	for _, o := range orderings {
+		if by := o[0].t.Sub(o[1].t); by > skewTolerance {
+			res = append(res, skewFinding{Earlier: o[0].name, Later: o[1].name, By: by})
+		}
+	}
+	return res
+}
+
+// recordSkew logs skew findings, and records them as events on the root span.
+func recordSkew(root *span.Span, findings []skewFinding) {
+	for _, f := range findings {
+		slog.Warn("clock skew detected", "earlier", f.Earlier, "later", f.Later, "by", f.By)
+		root.Event("clock_skew", root.Start,
+			attribute.String("earlier", f.Earlier),
+			attribute.String("later", f.Later),
+			attribute.Int64("skew_ms", f.By.Milliseconds()),
+		)
+	}
+}
+
+// podContainers returns the statuses of the containers of pod, which may be nil.
+func podContainers(pod *model.PodReport) []model.ContainerStatus {
+	if pod == nil {
+		return nil
+	}
+	return pod.Pod.Status.ContainerStatuses
+}
+
+// podInitContainers returns the statuses of the init containers of pod, which may be nil.
+func podInitContainers(pod *model.PodReport) []model.ContainerStatus {
+	if pod == nil {
+		return nil
+	}
+	return pod.Pod.Status.InitContainerStatuses
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/steps"
+	"github.com/howardjohn/prow-tracing/internal/tracing"
+)
+
+const spanUsage = `usage:
+  prow-tracing span start NAME [KEY=VALUE...]
+  prow-tracing span end NAME [KEY=VALUE...]
+  prow-tracing span exec NAME [KEY=VALUE...] -- COMMAND [ARGS...]`
+
+// spanCmd records a step of the job to the steps file in $ARTIFACTS.
+func spanCmd(args []string) {
+	if len(args) < 2 {
+		log.Fatal(spanUsage)
+	}
+	action, name, rest := args[0], args[1], args[2:]
+	tid, err := tracing.EnvIdentity().TraceID()
+	fatal(err)
+	path, err := steps.Path()
+	fatal(err)
+	record := func(phase steps.Phase, attrs map[string]string) {
+		fatal(steps.Append(path, steps.Record{
+			TraceID:    tid.String(),
+			Name:       name,
+			Phase:      phase,
+			Time:       time.Now(),
+			Attributes: attrs,
+		}))
+	}
+
+	switch action {
+	case "start":
+		attrs, err := parseAttributes(rest)
+		fatal(err)
+		record(steps.Start, attrs)
+	case "end":
+		attrs, err := parseAttributes(rest)
+		fatal(err)
+		record(steps.End, attrs)
+	case "exec":
+		attrArgs, command, ok := cutArgs(rest, "--")
+		if !ok || len(command) == 0 {
+			log.Fatal(spanUsage)
+		}
+		attrs, err := parseAttributes(attrArgs)
+		fatal(err)
+		record(steps.Start, attrs)
+		code := run(command)
+		record(steps.End, map[string]string{"exit_code": strconv.Itoa(code)})
+		os.Exit(code)
+	default:
+		log.Fatal(spanUsage)
+	}
+}
+
+// run executes the command, returning its exit code.
+func run(command []string) int {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		log.Printf("failed to run %v: %v", command[0], err)
+		return 127
+	}
+	return 0
+}
+
+func parseAttributes(args []string) (map[string]string, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	res := map[string]string{}
+	for _, a := range args {
+		k, v, ok := strings.Cut(a, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid attribute %q, expected KEY=VALUE", a)
+		}
+		res[k] = v
+	}
+	return res, nil
+}
+
+// cutArgs splits args around the first instance of sep.
+func cutArgs(args []string, sep string) ([]string, []string, bool) {
+	for i, a := range args {
+		if a == sep {
+			return args[:i], args[i+1:], true
+		}
+	}
+	return args, nil, false
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/span"
+	"github.com/howardjohn/prow-tracing/internal/stats"
+	"golang.org/x/exp/slog"
+)
+
+const statsUsage = `usage:
+  prow-tracing stats [--count N] [--since DURATION] [--output table|csv|json] JOB`
+
+// statsCmd reports the distribution of each span's duration across runs of a job.
+func statsCmd(args []string) {
+	flags := flag.NewFlagSet("stats", flag.ExitOnError)
+	count := flags.Int("count", 20, "number of most recent runs to include; 0 for no limit")
+	since := flags.Duration("since", 0, "only include runs that started within this long ago")
+	output := flags.String("output", "table", "output format: table, csv, or json")
+	opts := addBuildFlags(flags)
+	fatal(flags.Parse(args))
+	if flags.NArg() != 1 {
+		log.Fatal(statsUsage)
+	}
+
+	runs, err := fetchRuns(jobHistory(flags.Arg(0)), runOptions{Count: *count, Since: *since})
+	fatal(err)
+	slog.Info("fetched runs", "count", len(runs))
+	samples := stats.Samples{}
+	for _, job := range runs {
+		samples.Add(span.Durations(buildJob(job, *opts)))
+	}
+	fatal(writeStats(os.Stdout, samples.Summarize(), *output))
+}
+
+func writeStats(w io.Writer, summaries []stats.Summary, format string) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PATH\tRUNS\tP50\tP90\tP99\tMAX")
+		for _, s := range summaries {
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n", s.Path, s.Count, roundDuration(s.P50), roundDuration(s.P90), roundDuration(s.P99), roundDuration(s.Max))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"path", "runs", "p50_seconds", "p90_seconds", "p99_seconds", "max_seconds"})
+		for _, s := range summaries {
+			_ = cw.Write([]string{s.Path, strconv.Itoa(s.Count), seconds(s.P50), seconds(s.P90), seconds(s.P99), seconds(s.Max)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		type summary struct {
+			Path string  `json:"path"`
+			Runs int     `json:"runs"`
+			P50  float64 `json:"p50Seconds"`
+			P90  float64 `json:"p90Seconds"`
+			P99  float64 `json:"p99Seconds"`
+			Max  float64 `json:"maxSeconds"`
+		}
+		res := []summary{}
+		for _, s := range summaries {
+			res = append(res, summary{s.Path, s.Count, s.P50.Seconds(), s.P90.Seconds(), s.P99.Seconds(), s.Max.Seconds()})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// roundDuration rounds a duration for display in a table.
+func roundDuration(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Millisecond)
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
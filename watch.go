@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/howardjohn/prow-tracing/internal/metrics"
+	"github.com/howardjohn/prow-tracing/internal/tracing"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"golang.org/x/exp/slog"
+)
+
+const watchUsage = `usage:
+  prow-tracing watch [--interval DURATION] [--state FILE] [--once] JOB...`
+
+// watch polls jobs for runs as they finish, tracing each once. Progress is saved after each poll, so a
+// restarted watch resumes where it left off.
+func watch(args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := flags.Duration("interval", time.Minute, "how often to poll for new runs")
+	state := flags.String("state", "watch-state.json", "file to save progress to, and resume from")
+	backfill := flags.Int("backfill", 0, "number of each job's most recent finished runs to trace the first time it is watched; runs in progress are always traced")
+	once := flags.Bool("once", false, "poll once and exit, rather than polling forever")
+	opts := addBuildFlags(flags)
+	fatal(flags.Parse(args))
+	if flags.NArg() == 0 {
+		log.Fatal(watchUsage)
+	}
+
+	p := newPoller(flags.Args(), *backfill)
+	fatal(p.load(*state))
+	handle := traceRun(*opts)
+	for {
+		p.poll(handle)
+		fatal(p.save(*state))
+		if *once {
+			return
+		}
+		time.Sleep(*interval)
+	}
+}
+
+// traceRun returns a poller handler that exports the trace and metrics of each run, as prowjob does.
+func traceRun(opts buildOptions, exporters ...trace.SpanExporter) func(string, jobArtifacts) error {
+	return func(name string, job jobArtifacts) error {
+		slog.Info("tracing run", "job", name, "prowjob", job.prowjob.Name)
+		root := buildJob(job, opts)
+		if err := tracing.Export(job.prowjob, root, exporters...); err != nil {
+			return err
+		}
+		if err := metrics.Export(jobMetrics(job, root)); err != nil {
+			slog.Warn("failed to export metrics", "err", err)
+		}
+		return nil
+	}
+}